@@ -2,13 +2,21 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/generator"
 	"os"
-	"syncgen/internal/config"
-	"syncgen/internal/generator"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	buildOutputDir string
+	buildDryRun    bool
+	buildForce     bool
+	buildBundle    string
+)
+
 // buildCmd represents the build command
 var buildCmd = &cobra.Command{
 	Use:   "build [config file]",
@@ -18,39 +26,95 @@ files for high-availability PostgreSQL setup including:
 - Sync scripts for each replica
 - Systemd service and timer units  
 - Health check and failover scripts
-- Optional observability configurations`,
+- Optional observability configurations
+
+Config files with a clusters: map are built one cluster at a time with
+--cluster <name>, or all at once with --all, each into <output-dir>/<cluster>/.
+
+Build records what it wrote in manifest.json and refuses to overwrite
+files edited since the last build unless --force is given. Files for replicas
+removed from the config are deleted.
+
+With --bundle, build instead writes one artifact per node to
+<output-dir>/<cluster>/bundles/: a tar.gz, or a self-extracting shell archive
+(shar) that installs itself when run. Each contains the node's scripts, units
+and credentials, and an install.sh that copies them to /opt/ha-syncgen and
+runs the node's setup.
+
+Example usage:
+  syncgen build cluster.yaml
+  syncgen build cluster.yaml --output-dir /srv/syncgen --dry-run
+  syncgen build cluster.yaml --bundle shar`,
 	Args:                  cobra.ExactArgs(1),
 	DisableFlagsInUseLine: true,
 
 	Run: func(cmd *cobra.Command, args []string) {
+		if buildBundle != "" && buildBundle != "tar.gz" && buildBundle != "shar" {
+			fmt.Printf("Invalid bundle format '%s': must be one of [tar.gz shar]\n", buildBundle)
+			os.Exit(1)
+		}
+
 		configFile := args[0]
-		cfg, err := config.Parse(configFile)
+		configs, err := loadConfigs(configFile)
 		if err != nil {
 			fmt.Printf("Error parsing config file: %v\n", err)
-			return
+			os.Exit(1)
 		}
 
-		// Print the configuration for user verification
-		fmt.Println("Configuration validated successfully:")
-		config.Print(cfg)
+		for _, cfg := range configs {
+			// Print the configuration for user verification
+			fmt.Println("Configuration validated successfully:")
+			config.Print(cfg)
 
-		// Generate output directory
-		outputDir := "generated"
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			fmt.Printf("Error creating output directory: %v\n", err)
-			return
+			// Output directory is namespaced by cluster name when one is set
+			outputDir := filepath.Join(buildOutputDir, cfg.Cluster.Name)
+			sink := generator.NewDirSink(outputDir)
+			sink.Force = buildForce
+			gen := generator.New(cfg, sink)
+
+			if buildBundle != "" {
+				if err := writeBundles(gen, filepath.Join(outputDir, "bundles")); err != nil {
+					fmt.Printf("Error generating bundles: %v\n", err)
+					os.Exit(1)
+				}
+				continue
+			}
+
+			if buildDryRun {
+				plan, err := gen.Plan(outputDir)
+				if err != nil {
+					fmt.Printf("Error generating files: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("\nFiles that would be written to '%s/':\n", outputDir)
+				printPlan(plan)
+				continue
+			}
+
+			if err := gen.GenerateAll(); err != nil {
+				fmt.Printf("Error generating files: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("\n✅ HA PostgreSQL configuration generated successfully in '%s/' directory\n", outputDir)
 		}
 
-		// Initialize generator and create all files
-		gen := generator.New(cfg, outputDir)
-		if err := gen.GenerateAll(); err != nil {
-			fmt.Printf("Error generating files: %v\n", err)
+		if buildDryRun {
+			fmt.Println("\nDry run: no files were written")
 			return
 		}
 
-		fmt.Printf("\n✅ HA PostgreSQL configuration generated successfully in '%s/' directory\n", outputDir)
 		fmt.Println("\nNext steps:")
-		fmt.Printf("1. Review the generated scripts in '%s/'\n", outputDir)
+		if buildBundle != "" {
+			fmt.Println("1. Copy each node's bundle to that node")
+			if buildBundle == "shar" {
+				fmt.Println("2. Run it as root: sudo sh <node>.shar, primary first")
+			} else {
+				fmt.Println("2. Extract it and run install.sh as root: tar xzf <node>.tar.gz && sudo <node>/install.sh, primary first")
+			}
+			fmt.Println("\nFor deployment help, run: syncgen --help")
+			return
+		}
+		fmt.Printf("1. Review the generated scripts in '%s/'\n", buildOutputDir)
 		fmt.Println("2. Copy the scripts to your target servers")
 		fmt.Println("3. Set up PostgreSQL streaming replication by running the setup scripts")
 		fmt.Println("4. Install and enable the systemd services for automatic health monitoring")
@@ -58,7 +122,74 @@ files for high-availability PostgreSQL setup including:
 	},
 }
 
+// writeBundles writes one archive per node to dir, or lists them on a dry run
+func writeBundles(gen *generator.Generator, dir string) error {
+	bundles, err := gen.Bundles()
+	if err != nil {
+		return err
+	}
+	if !buildDryRun {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	for _, bundle := range bundles {
+		artifact := filepath.Join(dir, bundle.Node+"."+buildBundle)
+		if buildDryRun {
+			fmt.Printf("\nBundle that would be written to '%s':\n", artifact)
+			for _, file := range bundle.Files {
+				fmt.Printf("  %10s %8d  %s\n", file.Mode, len(file.Content), file.Path)
+			}
+			continue
+		}
+
+		mode := os.FileMode(0600)
+		if buildBundle == "shar" {
+			mode = 0700
+		}
+		// Bundles contain credentials, so keep them private to the user running build
+		f, err := os.OpenFile(artifact, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		var sink generator.Sink = generator.NewTarSink(f)
+		if buildBundle == "shar" {
+			shar := generator.NewSharSink(f)
+			shar.Run = bundle.Node + "/install.sh"
+			sink = shar
+		}
+		err = generator.WriteBundle(bundle, sink)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n✅ Bundle for %s written to '%s'\n", bundle.Node, artifact)
+	}
+	return nil
+}
+
+// printPlan lists every change in a write plan with its mode and size
+func printPlan(plan *generator.Plan) {
+	for _, change := range plan.Changes {
+		note := ""
+		if change.Modified {
+			note = "  (locally modified, needs --force)"
+		}
+		if change.Action == generator.Remove {
+			fmt.Printf("  %-9s %10s %8s  %s%s\n", change.Action, "", "", change.Path, note)
+			continue
+		}
+		fmt.Printf("  %-9s %10s %8d  %s%s\n", change.Action, change.Mode, change.Size, change.Path, note)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(buildCmd)
-	// No flags configured for build command yet
+	buildCmd.Flags().StringVar(&buildOutputDir, "output-dir", "generated", "directory to write generated files to")
+	buildCmd.Flags().BoolVar(&buildDryRun, "dry-run", false, "list the files that would be written without writing them")
+	buildCmd.Flags().BoolVar(&buildForce, "force", false, "overwrite or remove files modified since the last build")
+	buildCmd.Flags().StringVar(&buildBundle, "bundle", "", "write one installable artifact per node: tar.gz or shar")
 }
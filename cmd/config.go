@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// configCmd groups commands that inspect configuration files
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect cluster configuration files",
+}
+
+// configRenderCmd prints the merged and defaulted configuration
+var configRenderCmd = &cobra.Command{
+	Use:   "render [config file]",
+	Short: "Print the fully merged, defaulted configuration",
+	Long: `Render merges any -f overlays over the base config, applies the defaults
+used by build and validates the result, then prints the configuration that would
+be used to generate files. Use it to review environment overlays.
+
+Example usage:
+  syncgen config render cluster.yaml -f prod.yaml
+  syncgen config render clusters.yaml --cluster orders
+  syncgen config render clusters.yaml --all`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configs, err := loadConfigs(args[0])
+		if err != nil {
+			fmt.Printf("Error parsing config file: %v\n", err)
+			os.Exit(1)
+		}
+		for i, cfg := range configs {
+			if i > 0 {
+				fmt.Println("---")
+			}
+			if cfg.Cluster.Name != "" {
+				fmt.Printf("# cluster: %s\n", cfg.Cluster.Name)
+			}
+			if err := config.Render(os.Stdout, cfg); err != nil {
+				fmt.Printf("Error rendering config: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configRenderCmd)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/diff"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/generator"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var diffOutputDir string
+
+// diffCmd compares a fresh build against the output directory
+var diffCmd = &cobra.Command{
+	Use:   "diff [config file]",
+	Short: "Show how the generated files on disk differ from a fresh build",
+	Long: `Diff renders cluster.yaml in memory and prints a unified diff against the
+files in the output directory, without writing anything. It shows both files
+that were edited by hand and files a rebuild would change after the config or
+templates changed.
+
+Diff exits with status 1 when the output directory has drifted, so it can be
+used in CI to check that committed output is up to date.
+
+Example usage:
+  syncgen diff cluster.yaml
+  syncgen diff clusters.yaml --all --output-dir deploy/`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configs, err := loadConfigs(args[0])
+		if err != nil {
+			fmt.Printf("Error parsing config file: %v\n", err)
+			os.Exit(1)
+		}
+
+		drifted := false
+		for _, cfg := range configs {
+			outputDir := filepath.Join(diffOutputDir, cfg.Cluster.Name)
+			plan, err := generator.New(cfg, nil).Plan(outputDir)
+			if err != nil {
+				fmt.Printf("Error generating files: %v\n", err)
+				os.Exit(1)
+			}
+
+			modified := 0
+			for _, change := range plan.Changes {
+				if change.Action == generator.Unchanged {
+					continue
+				}
+				if change.Modified {
+					modified++
+				}
+				if err := printChange(plan, change); err != nil {
+					fmt.Printf("Error reading %s: %v\n", change.Path, err)
+					os.Exit(1)
+				}
+			}
+
+			if plan.Drifted() {
+				drifted = true
+				fmt.Fprintf(os.Stderr, "%s/ differs from a fresh build (%d edited since the last build)\n", outputDir, modified)
+			}
+		}
+
+		if drifted {
+			os.Exit(1)
+		}
+		fmt.Println("No differences")
+	},
+}
+
+// printChange prints the unified diff from the file on disk to the rendered file
+func printChange(plan *generator.Plan, change generator.Change) error {
+	path := filepath.Join(plan.Dir, filepath.FromSlash(change.Path))
+	oldName, newName := path, path
+	var old, rendered []byte
+
+	if change.Action == generator.Create {
+		oldName = "/dev/null"
+	} else {
+		var err error
+		if old, err = os.ReadFile(path); err != nil {
+			return err
+		}
+	}
+	if change.Action == generator.Remove {
+		newName = "/dev/null"
+	} else {
+		file, _ := plan.File(change.Path)
+		rendered = file.Content
+	}
+
+	fmt.Print(diff.Unified(oldName, newName, old, rendered))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringVar(&diffOutputDir, "output-dir", "generated", "directory the files were generated into")
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/doctor"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorSSHUser      string
+	doctorSSHPort      int
+	doctorIdentityFile string
+	doctorTimeout      time.Duration
+	doctorOutput       string
+)
+
+// doctorCmd runs pre-flight checks against the hosts in the config
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [config file]",
+	Short: "Check that the hosts in cluster.yaml are ready for the generated scripts",
+	Long: `Doctor connects to every node in the config and checks that the generated
+scripts can run there:
+- the PostgreSQL port is reachable and ssh access works
+- the data directory exists on the primary
+- PostgreSQL is 13 or newer and the same major version on every node
+- psql, pg_basebackup, pg_rewind and nc are installed
+- replicas have enough free disk space for the primary's databases
+- clocks agree across nodes
+- the primary supports FOR TABLES IN SCHEMA when a publication lists schemas
+
+With --all, each JSON report carries the name of its cluster.
+
+Commands run over the system ssh client in batch mode, so key-based access must
+already be set up. The database size check needs passwordless sudo to postgres.
+
+Example usage:
+  syncgen doctor cluster.yaml
+  syncgen doctor cluster.yaml --ssh-user admin --identity-file ~/.ssh/db
+  syncgen doctor clusters.yaml --all --output json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if doctorOutput != "text" && doctorOutput != "json" {
+			fmt.Printf("Invalid output format '%s': must be one of [text json]\n", doctorOutput)
+			os.Exit(1)
+		}
+
+		configs, err := loadConfigs(args[0])
+		if err != nil {
+			fmt.Printf("Error parsing config file: %v\n", err)
+			os.Exit(1)
+		}
+
+		prober := &doctor.SSHProber{User: doctorSSHUser, Port: doctorSSHPort, IdentityFile: doctorIdentityFile}
+		failed := false
+		var reports []*doctor.Report
+		for _, cfg := range configs {
+			report := doctor.New(cfg, prober, doctorTimeout).Run(context.Background())
+			failed = failed || report.Failed()
+			reports = append(reports, report)
+
+			if doctorOutput == "text" {
+				if cfg.Cluster.Name != "" {
+					fmt.Printf("Cluster %s\n", cfg.Cluster.Name)
+				}
+				report.Print(os.Stdout)
+			}
+		}
+
+		if doctorOutput == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(reports)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().StringVar(&doctorSSHUser, "ssh-user", "", "user for ssh connections (default from ssh config)")
+	doctorCmd.Flags().IntVar(&doctorSSHPort, "ssh-port", 0, "port for ssh connections (default from ssh config)")
+	doctorCmd.Flags().StringVar(&doctorIdentityFile, "identity-file", "", "private key for ssh connections")
+	doctorCmd.Flags().DurationVar(&doctorTimeout, "timeout", 10*time.Second, "timeout for each check")
+	doctorCmd.Flags().StringVarP(&doctorOutput, "output", "o", "text", "output format: text or json")
+}
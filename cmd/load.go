@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"os"
+)
+
+var (
+	clusterName  string
+	allClusters  bool
+	overlayFiles []string
+	strict       bool
+)
+
+// loadConfigs parses the config file, merges any -f overlays over it in order and
+// returns the clusters selected by the --cluster and --all flags. Single-cluster
+// files need neither flag. Validation warnings are printed to stderr.
+func loadConfigs(configFile string) ([]*config.Config, error) {
+	configs, warnings, err := checkConfigs(configFile)
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "%v\n", warning)
+	}
+	return configs, err
+}
+
+// checkConfigs is loadConfigs without printing, returning the validation warnings
+// of the selected clusters. With --strict warnings are returned as errors.
+func checkConfigs(configFile string) ([]*config.Config, []*config.ValidationError, error) {
+	if clusterName != "" && allClusters {
+		return nil, nil, fmt.Errorf("--cluster and --all cannot be used together")
+	}
+
+	doc, err := config.Load(configFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc.Strict = strict
+	for _, overlay := range overlayFiles {
+		if err := doc.Overlay(overlay); err != nil {
+			return nil, nil, fmt.Errorf("overlay %s: %w", overlay, err)
+		}
+	}
+	if allClusters {
+		configs, err := doc.All()
+		return configs, doc.Warnings(), err
+	}
+	cfg, err := doc.Cluster(clusterName)
+	if err != nil {
+		return nil, doc.Warnings(), err
+	}
+	return []*config.Config{cfg}, doc.Warnings(), nil
+}
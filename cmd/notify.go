@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/notify"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	notifyEvent string
+	notifySink  string
+)
+
+// notifyCmd groups the notification related commands
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Work with failover notification sinks",
+}
+
+// notifyTestCmd fires a sample event at the configured notification sinks
+var notifyTestCmd = &cobra.Command{
+	Use:   "test [config file]",
+	Short: "Send a sample failover event to the configured notification sinks",
+	Long: `Send a sample event through every notification sink in cluster.yaml that
+subscribes to it, using the same payloads and retry policy as the generated
+health check scripts.
+
+Example usage:
+  syncgen notify test cluster.yaml
+  syncgen notify test cluster.yaml --event promotion_failed
+  syncgen notify test cluster.yaml --sink ops-slack`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !slices.Contains(config.NotificationEvents, notifyEvent) {
+			fmt.Printf("Invalid event '%s': must be one of %v\n", notifyEvent, config.NotificationEvents)
+			os.Exit(1)
+		}
+
+		configs, err := loadConfigs(args[0])
+		if err != nil {
+			fmt.Printf("Error parsing config file: %v\n", err)
+			os.Exit(1)
+		}
+		if len(configs) != 1 {
+			fmt.Println("notify test works on one cluster at a time; select it with --cluster")
+			os.Exit(1)
+		}
+		cfg := configs[0]
+		if cfg.Notifications == nil {
+			fmt.Println("No notifications section configured in the config file")
+			os.Exit(1)
+		}
+
+		notifier, err := notify.New(cfg.Notifications)
+		if err != nil {
+			fmt.Printf("Error configuring notifications: %v\n", err)
+			os.Exit(1)
+		}
+
+		node := cfg.Primary.Host
+		if len(cfg.Replicas) > 0 {
+			node = cfg.Replicas[0].Host
+		}
+		event := notify.Event{
+			Name:      notifyEvent,
+			Message:   fmt.Sprintf("Test %s notification from syncgen", notifyEvent),
+			Node:      node,
+			Primary:   cfg.Primary.Host,
+			Cluster:   cfg.Cluster.Name,
+			Timestamp: time.Now(),
+		}
+		if err := notifier.Send(event, notifySink); err != nil {
+			fmt.Printf("Error sending test notification:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Test '%s' notification delivered\n", notifyEvent)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(notifyCmd)
+	notifyCmd.AddCommand(notifyTestCmd)
+	notifyTestCmd.Flags().StringVar(&notifyEvent, "event", "promoted", "event to send (warning, primary_down, promoted, promotion_failed)")
+	notifyTestCmd.Flags().StringVar(&notifySink, "sink", "", "only send to the sink with this name")
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/generator"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	restoreNode       string
+	restoreTargetTime string
+	restoreTargetLSN  string
+	restoreOutput     string
+)
+
+// restorePlanCmd renders a point-in-time restore script for one node
+var restorePlanCmd = &cobra.Command{
+	Use:   "restore-plan [config file]",
+	Short: "Generate a point-in-time restore script for a node",
+	Long: `Restore-plan renders restore.sh, a guided point-in-time recovery for one
+node using the backup settings in cluster.yaml. Run on the node as root, the
+script stops PostgreSQL, moves the data directory aside, restores the latest
+base backup (or one named on its command line), writes the recovery target and
+recovery.signal, starts PostgreSQL and waits for it to reach the target.
+
+A restored primary is promoted on a new timeline, so the other nodes must be
+re-seeded from it afterwards. The script first asks for the replicas' health
+checks to be stopped, so they do not promote themselves while it is down. A
+restored replica pauses read-only at the target instead, because the primary
+is still running and a second writable node would split the cluster. The
+config needs a backup tool; see backup.tool.
+
+Target times without a UTC offset are interpreted in the server's time zone.
+
+Example usage:
+  syncgen restore-plan cluster.yaml --target-time 2024-05-01T14:30:00Z -o restore.sh
+  syncgen restore-plan cluster.yaml --node 10.0.0.2 --target-lsn 0/3000060`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configs, err := loadConfigs(args[0])
+		if err != nil {
+			fmt.Printf("Error parsing config file: %v\n", err)
+			os.Exit(1)
+		}
+		if len(configs) != 1 {
+			fmt.Println("restore-plan works on one cluster at a time; select it with --cluster")
+			os.Exit(1)
+		}
+
+		target := generator.RestoreTarget{Node: restoreNode, Time: restoreTargetTime, LSN: restoreTargetLSN}
+		script, err := generator.New(configs[0], nil).RestorePlan(target)
+		if err != nil {
+			fmt.Printf("Error generating restore plan: %v\n", err)
+			os.Exit(1)
+		}
+
+		if restoreOutput == "" {
+			os.Stdout.Write(script.Content)
+			return
+		}
+		if err := os.WriteFile(restoreOutput, script.Content, script.Mode); err != nil {
+			fmt.Printf("Error writing restore plan: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Restore plan written to %s; copy it to the node and run it as root\n", restoreOutput)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restorePlanCmd)
+	restorePlanCmd.Flags().StringVar(&restoreNode, "node", "primary", "node to restore: primary or a replica host")
+	restorePlanCmd.Flags().StringVar(&restoreTargetTime, "target-time", "", "recover to this time, e.g. 2024-05-01T14:30:00Z")
+	restorePlanCmd.Flags().StringVar(&restoreTargetLSN, "target-lsn", "", "recover to this WAL location, e.g. 0/3000060")
+	restorePlanCmd.Flags().StringVarP(&restoreOutput, "output", "o", "", "write the script to this file instead of stdout")
+	restorePlanCmd.MarkFlagsMutuallyExclusive("target-time", "target-lsn")
+	restorePlanCmd.MarkFlagsOneRequired("target-time", "target-lsn")
+}
@@ -16,6 +16,7 @@ limitations under the License.
 package cmd
 
 import (
+	"github.com/HasithDeAlwis/ha-syncgen/internal/version"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -39,11 +40,17 @@ establish a primary-replica PostgreSQL setup with:
 Usage examples:
   syncgen validate ha-config.yaml # Validate configuration
   syncgen build [config file]               # Generate HA scripts and configuration
+  syncgen build clusters.yaml --all         # Generate every cluster into generated/<cluster>/
+  syncgen build base.yaml -f prod.yaml      # Merge an environment overlay before generating
+  syncgen config render base.yaml -f prod.yaml  # Print the merged, defaulted config
+  syncgen doctor cluster.yaml               # Pre-flight checks against the real hosts
+  syncgen diff cluster.yaml                 # Show how generated/ differs from a fresh build
   //  COMING SOON
   syncgen status                           # Check current HA status
   syncgen failover                         # Manual failover to replica
 
 For more information, visit: https://github.com/HasithDeAlwis/ha-syncgen`,
+	Version: version.String(),
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
@@ -62,6 +69,16 @@ func init() {
 	// Global configuration flags that apply to all subcommands
 	// These flags will be available across all ha-syncgen commands
 
+	// Cluster selection for config files that define several clusters under `clusters:`
+	rootCmd.PersistentFlags().StringVar(&clusterName, "cluster", "", "cluster to use from a multi-cluster config file")
+	rootCmd.PersistentFlags().BoolVar(&allClusters, "all", false, "use every cluster in a multi-cluster config file")
+
+	// Overlay files merged over the base config in order, e.g. -f prod.yaml
+	rootCmd.PersistentFlags().StringArrayVarP(&overlayFiles, "file", "f", nil, "overlay config file merged over the base config (repeatable)")
+
+	// Treat validation warnings such as a sync replica with synchronous_commit off as errors
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "fail on validation warnings")
+
 	// Config file flag - allows users to specify custom config location
 	// Default behavior will look for config in a standard location:
 	// 1. ./cluster.yaml (current directory)
@@ -69,7 +86,4 @@ func init() {
 
 	// Verbose output flag for debugging and detailed operation logs
 	// rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output for debugging")
-
-	// Dry-run flag to preview operations without making actual changes
-	// rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "preview operations without executing them")
 }
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var schemaOutput string
+
+// schemaCmd prints the JSON schema of cluster config files
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON schema for cluster config files",
+	Long: `Print a JSON schema describing cluster.yaml, including allowed values,
+defaults and required fields. Point your editor's YAML language server at it for
+autocompletion, or use it to validate config files in CI.
+
+Example usage:
+  syncgen schema > cluster.schema.json
+  syncgen schema -o cluster.schema.json
+
+Then reference it from the top of a config file:
+  # yaml-language-server: $schema=./cluster.schema.json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := json.MarshalIndent(config.Schema(), "", "  ")
+		if err != nil {
+			fmt.Printf("Error generating schema: %v\n", err)
+			os.Exit(1)
+		}
+		data = append(data, '\n')
+
+		if schemaOutput == "" {
+			os.Stdout.Write(data)
+			return
+		}
+		if err := os.WriteFile(schemaOutput, data, 0644); err != nil {
+			fmt.Printf("Error writing schema: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Schema written to %s\n", schemaOutput)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+	schemaCmd.Flags().StringVarP(&schemaOutput, "output", "o", "", "write the schema to a file instead of stdout")
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/doctor"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/generator"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	slotsDryRun       bool
+	slotsSSHUser      string
+	slotsSSHPort      int
+	slotsIdentityFile string
+	slotsTimeout      time.Duration
+)
+
+// slotsCmd groups the replication slot commands
+var slotsCmd = &cobra.Command{
+	Use:   "slots",
+	Short: "Manage replication slots on the primary",
+}
+
+// slotsReconcileCmd runs the generated reconcile_slots.sh on the primary over ssh
+var slotsReconcileCmd = &cobra.Command{
+	Use:   "reconcile [config file]",
+	Short: "Create missing replication slots and drop abandoned ones on the primary",
+	Long: `Reconcile runs the generated reconcile_slots.sh on the primary over ssh. It
+creates the slots of replicas that stream from the primary and drops inactive
+physical slots that no replica in cluster.yaml uses, since an abandoned slot
+retains WAL until the primary's disk fills. Slots still in use are reported
+and left alone, as are logical slots.
+
+The script runs psql through sudo -u postgres, so the ssh user needs
+passwordless sudo. Use --dry-run to list the changes first.
+
+Example usage:
+  syncgen slots reconcile cluster.yaml --dry-run
+  syncgen slots reconcile cluster.yaml --ssh-user admin --identity-file ~/.ssh/db`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configs, err := loadConfigs(args[0])
+		if err != nil {
+			fmt.Printf("Error parsing config file: %v\n", err)
+			os.Exit(1)
+		}
+		if len(configs) != 1 {
+			fmt.Println("slots reconcile works on one cluster at a time; select it with --cluster")
+			os.Exit(1)
+		}
+		cfg := configs[0]
+
+		files, err := generator.New(cfg, nil).Render()
+		if err != nil {
+			fmt.Printf("Error generating files: %v\n", err)
+			os.Exit(1)
+		}
+		var script string
+		for _, file := range files {
+			if file.Path == "primary/reconcile_slots.sh" {
+				script = string(file.Content)
+			}
+		}
+
+		command := "bash -c " + generator.ShellQuote(script) + " reconcile_slots.sh"
+		if slotsDryRun {
+			command += " --dry-run"
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), slotsTimeout)
+		defer cancel()
+		prober := &doctor.SSHProber{User: slotsSSHUser, Port: slotsSSHPort, IdentityFile: slotsIdentityFile}
+		output, err := prober.Run(ctx, cfg.Primary.Host, command)
+		fmt.Print(output)
+		if err != nil {
+			fmt.Printf("Error reconciling slots on %s: %v\n", cfg.Primary.Host, err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(slotsCmd)
+	slotsCmd.AddCommand(slotsReconcileCmd)
+	slotsReconcileCmd.Flags().BoolVar(&slotsDryRun, "dry-run", false, "list the slots that would be created or dropped without changing them")
+	slotsReconcileCmd.Flags().StringVar(&slotsSSHUser, "ssh-user", "", "user for ssh connections (default from ssh config)")
+	slotsReconcileCmd.Flags().IntVar(&slotsSSHPort, "ssh-port", 0, "port for ssh connections (default from ssh config)")
+	slotsReconcileCmd.Flags().StringVar(&slotsIdentityFile, "identity-file", "", "private key for ssh connections")
+	slotsReconcileCmd.Flags().DurationVar(&slotsTimeout, "timeout", time.Minute, "timeout for the reconciliation")
+}
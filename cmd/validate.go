@@ -4,12 +4,16 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
-	"syncgen/internal/config"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"os"
 
 	"github.com/spf13/cobra"
 )
 
+var validateOutput string
+
 // validateCmd represents the validate command
 var validateCmd = &cobra.Command{
 	Use:   "validate",
@@ -46,18 +50,64 @@ Error: Primary host is required in the configuration file. Replicas are not prov
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		configFile := args[0]
-		cfg, err := config.Parse(configFile)
+		configs, warnings, err := checkConfigs(configFile)
+
+		switch validateOutput {
+		case "json":
+			printValidationJSON(configFile, warnings, err)
+			if err != nil {
+				os.Exit(1)
+			}
+			return
+		case "text":
+		default:
+			fmt.Printf("Invalid output format '%s': must be one of [text json]\n", validateOutput)
+			os.Exit(1)
+		}
+
+		for _, warning := range warnings {
+			fmt.Printf("%v\n", warning)
+		}
 		if err != nil {
 			fmt.Printf("%v\n", err)
-			return
+			os.Exit(1)
+		}
+		for _, cfg := range configs {
+			fmt.Println("Validation successful!")
+			config.Print(cfg)
 		}
-		fmt.Printf("Validation successful!")
-		config.Print(cfg)
 	},
 }
 
+// validationReport is the --output json document, suitable for CI annotations
+type validationReport struct {
+	Valid  bool                      `json:"valid"`
+	Issues []*config.ValidationError `json:"issues"`
+}
+
+func printValidationJSON(configFile string, warnings []*config.ValidationError, err error) {
+	report := validationReport{Valid: err == nil, Issues: config.ValidationErrors(err)}
+	if err != nil && len(report.Issues) == 0 {
+		// The file could not be read or parsed, so there is no field to point at
+		report.Issues = append(report.Issues, &config.ValidationError{
+			File:     configFile,
+			Severity: config.SeverityError,
+			Message:  err.Error(),
+		})
+	}
+	report.Issues = append(report.Issues, warnings...)
+	if report.Issues == nil {
+		report.Issues = []*config.ValidationError{}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+}
+
 func init() {
 	rootCmd.AddCommand(validateCmd)
+	validateCmd.Flags().StringVarP(&validateOutput, "output", "o", "text", "output format: text or json")
 
 	// Here you will define your flags and configuration settings.
 
@@ -9,7 +9,7 @@ import (
 
 	"github.com/joho/godotenv"
 
-	"syncgen/internal/config"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
 )
 
 // DeploymentData holds data for deployment script generation
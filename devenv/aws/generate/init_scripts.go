@@ -7,7 +7,7 @@ import (
 	"runtime"
 	"text/template"
 
-	"syncgen/internal/config"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
 )
 
 // InitScriptData holds the data for SQL init script template rendering
@@ -5,7 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
-	"syncgen/internal/config"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
 )
 
 func mockConfig() *config.Config {
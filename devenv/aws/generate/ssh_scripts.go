@@ -8,7 +8,7 @@ import (
 	"runtime"
 	"text/template"
 
-	"syncgen/internal/config"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
 
 	"github.com/joho/godotenv"
 )
@@ -3,6 +3,7 @@ package generate
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
 	"io"
 	"os"
 	"path/filepath"
@@ -10,7 +11,6 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"syncgen/internal/config"
 
 	yaml "gopkg.in/yaml.v3"
 )
@@ -6,7 +6,7 @@ import (
 	"reflect"
 	"testing"
 
-	"syncgen/internal/config"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
 
 	"gopkg.in/yaml.v3"
 )
@@ -2,11 +2,11 @@ package main
 
 import (
 	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/devenv/aws/generate"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
 	"os"
 	"path/filepath"
 	"runtime"
-	"syncgen/devenv/aws/generate"
-	"syncgen/internal/config"
 )
 
 func main() {
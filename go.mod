@@ -1,4 +1,4 @@
-module syncgen
+module github.com/HasithDeAlwis/ha-syncgen
 
 go 1.23.3
 
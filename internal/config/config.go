@@ -2,11 +2,16 @@ package config
 
 import (
 	"fmt"
-	"os"
-
-	"gopkg.in/yaml.v3"
+	"sort"
+	"strings"
 )
 
+type Cluster struct {
+	Name        string            `yaml:"name"`
+	Environment string            `yaml:"environment,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+}
+
 type Primary struct {
 	Host                string `yaml:"host"`
 	Port                int    `yaml:"port"`
@@ -25,15 +30,89 @@ type Replica struct {
 	SyncMode        string `yaml:"sync_mode"`
 	DbUser          string `yaml:"db_user"`
 	DbPassword      string `yaml:"db_password"`
+	// Upstream is the host of another replica to stream from instead of the primary
+	Upstream string `yaml:"upstream,omitempty"`
+	// RecoveryMinApplyDelay makes a delayed standby that replays WAL this long
+	// after it was written, e.g. "4h", so bad writes can be recovered from it
+	RecoveryMinApplyDelay string `yaml:"recovery_min_apply_delay,omitempty"`
+}
+
+// Delayed reports whether the replica applies WAL with a delay. Delayed
+// replicas are never promoted automatically.
+func (r Replica) Delayed() bool {
+	match := applyDelayPattern.FindStringSubmatch(r.RecoveryMinApplyDelay)
+	return match != nil && strings.Trim(match[1], "0") != ""
 }
 
 type Options struct {
-	PromoteOnFailure  bool   `yaml:"promote_on_failure"`
-	WalLevel          string `yaml:"wal_level"`
-	MaxWalSenders     int    `yaml:"max_wal_senders"`
-	WalKeepSize       string `yaml:"wal_keep_size"`
-	HotStandby        bool   `yaml:"hot_standby"`
-	SynchronousCommit string `yaml:"synchronous_commit"`
+	PromoteOnFailure bool   `yaml:"promote_on_failure"`
+	WalLevel         string `yaml:"wal_level"`
+	MaxWalSenders    int    `yaml:"max_wal_senders"`
+	// MaxReplicationSlots defaults to one slot per replica plus headroom
+	MaxReplicationSlots int    `yaml:"max_replication_slots"`
+	WalKeepSize         string `yaml:"wal_keep_size"`
+	// MaxSlotWalKeepSize caps the WAL a replication slot can retain; slots that
+	// fall further behind are invalidated. Unset or -1 means no limit.
+	MaxSlotWalKeepSize string `yaml:"max_slot_wal_keep_size,omitempty"`
+	// SlotWalWarningSize is how much WAL a slot may retain before the health
+	// check warns
+	SlotWalWarningSize string `yaml:"slot_wal_warning_size"`
+	HotStandby         bool   `yaml:"hot_standby"`
+	SynchronousCommit  string `yaml:"synchronous_commit"`
+	// SyncQuorum controls how many sync replicas must confirm each commit
+	SyncQuorum SyncQuorum `yaml:"sync_quorum"`
+	// Archive controls WAL archiving on the primary and promoted replicas
+	Archive ArchiveOptions `yaml:"archive"`
+}
+
+// ArchiveOptions set archive_mode and archive_command. Without a backup tool
+// WAL is copied to Directory; Command replaces the generated archive_command.
+type ArchiveOptions struct {
+	Enabled *bool `yaml:"enabled"`
+	// Directory defaults to "archive" next to the primary's data directory
+	Directory string `yaml:"directory"`
+	Command   string `yaml:"command,omitempty"`
+	// Timeout is archive_timeout: the longest a WAL segment waits to be archived
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// SyncQuorum is the method and count written to synchronous_standby_names.
+// With "first" the first NumSync connected sync replicas in config order are
+// synchronous; with "any" any NumSync of them must confirm each commit.
+type SyncQuorum struct {
+	Method  string `yaml:"method"`
+	NumSync int    `yaml:"num_sync"`
+}
+
+// LogicalSubscriber is a separate PostgreSQL server that receives some of the
+// primary's tables through logical replication. It is not part of failover.
+type LogicalSubscriber struct {
+	// Name is used for the subscription and its replication slot on the primary
+	Name string `yaml:"name"`
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	// DbName is the database on the subscriber; it defaults to primary.db_name
+	DbName       string              `yaml:"db_name"`
+	Publications []Publication       `yaml:"publications"`
+	Subscription SubscriptionOptions `yaml:"subscription,omitempty"`
+}
+
+// Publication is a set of tables published from primary.db_name, listed by
+// name or by schema. Subscribers can share a publication by repeating it.
+type Publication struct {
+	Name   string   `yaml:"name"`
+	Tables []string `yaml:"tables,omitempty"`
+	// Schemas publishes every table in each schema with FOR TABLES IN SCHEMA,
+	// which needs PostgreSQL 15 or newer on the primary
+	Schemas []string `yaml:"schemas,omitempty"`
+}
+
+// SubscriptionOptions are passed to CREATE SUBSCRIPTION ... WITH
+type SubscriptionOptions struct {
+	// CopyData copies the existing table contents before streaming changes
+	CopyData  *bool `yaml:"copy_data,omitempty"`
+	Binary    bool  `yaml:"binary,omitempty"`
+	Streaming bool  `yaml:"streaming,omitempty"`
 }
 
 type Monitoring struct {
@@ -41,33 +120,334 @@ type Monitoring struct {
 }
 
 type DatadogConfig struct {
-	Enabled             bool   `yaml:"enabled"`
-	ApiKey              string `yaml:"api_key"`
-	Site                string `yaml:"site"`
-	DatadogUserPassword string `yaml:"datadog_user_password"`
+	Enabled             bool     `yaml:"enabled"`
+	ApiKey              string   `yaml:"api_key"`
+	Site                string   `yaml:"site"`
+	DatadogUserPassword string   `yaml:"datadog_user_password"`
+	AgentVersion        string   `yaml:"agent_version"`
+	InstallScriptSHA256 string   `yaml:"install_script_sha256,omitempty"`
+	Tags                []string `yaml:"tags,omitempty"`
+}
+
+type Routing struct {
+	HAProxy HAProxyConfig `yaml:"haproxy"`
+	VIP     *VIPConfig    `yaml:"vip,omitempty"`
+}
+
+// HAProxyConfig describes an HAProxy in front of the cluster with a read-write
+// port that follows the primary and a read-only port balanced across replicas.
+// HAProxy learns each node's role over HTTP on CheckPort, from a role check
+// installed on every node.
+type HAProxyConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Bind          string `yaml:"bind"`
+	ReadWritePort int    `yaml:"read_write_port"`
+	ReadOnlyPort  int    `yaml:"read_only_port"`
+	CheckPort     int    `yaml:"check_port"`
+	CheckInterval string `yaml:"check_interval"`
+	// StatsPort serves the HAProxy stats page; 0 disables it
+	StatsPort int `yaml:"stats_port,omitempty"`
+}
+
+// VIPConfig describes a virtual IP that Keepalived moves to whichever node is
+// the writable primary, for clients that connect to PostgreSQL directly
+type VIPConfig struct {
+	// Address is an IP address, optionally with a prefix length (10.0.0.100/24)
+	Address   string `yaml:"address"`
+	Interface string `yaml:"interface"`
+	// RouterID is the VRRP virtual router ID, unique on the network segment
+	RouterID int `yaml:"router_id"`
+}
+
+type Pooling struct {
+	PgBouncer PgBouncerConfig `yaml:"pgbouncer"`
+}
+
+// PgBouncerConfig describes a PgBouncer on every node, pooling connections to
+// Primary.DbName on whichever node is primary. Connections authenticate as
+// Primary.DbUser, which PgBouncer also uses to look up other users.
+type PgBouncerConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	ListenPort      int    `yaml:"listen_port"`
+	PoolMode        string `yaml:"pool_mode"`
+	DefaultPoolSize int    `yaml:"default_pool_size"`
+	MaxClientConn   int    `yaml:"max_client_conn"`
+	// ReservePoolSize allows extra server connections when a pool is exhausted
+	ReservePoolSize int `yaml:"reserve_pool_size,omitempty"`
+	// Binary is the absolute path of the pgbouncer executable on the nodes
+	Binary string `yaml:"binary"`
+}
+
+// Backup selects the tool that archives WAL from the primary, restores it on
+// replicas that fall behind, and takes scheduled base backups
+type Backup struct {
+	Tool string `yaml:"tool"`
+	// Stanza names the cluster in the pgBackRest repository
+	Stanza     string           `yaml:"stanza,omitempty"`
+	Repository BackupRepository `yaml:"repository"`
+	// FullSchedule and DiffSchedule are systemd OnCalendar expressions
+	FullSchedule  string `yaml:"full_schedule"`
+	DiffSchedule  string `yaml:"diff_schedule,omitempty"`
+	RetentionFull int    `yaml:"retention_full"`
+}
+
+// BackupRepository is where backups and archived WAL are stored: a directory,
+// which must be shared storage for replicas to restore from it, or S3
+type BackupRepository struct {
+	Type string `yaml:"type"`
+	// Path is a directory for posix repositories and a key prefix for S3
+	Path      string `yaml:"path"`
+	Bucket    string `yaml:"bucket,omitempty"`
+	Region    string `yaml:"region,omitempty"`
+	Endpoint  string `yaml:"endpoint,omitempty"`
+	AccessKey string `yaml:"access_key,omitempty"`
+	SecretKey string `yaml:"secret_key,omitempty"`
+}
+
+type Notifications struct {
+	Retries    int                `yaml:"retries"`
+	RetryDelay string             `yaml:"retry_delay"`
+	Sinks      []NotificationSink `yaml:"sinks"`
+}
+
+type NotificationSink struct {
+	Name    string            `yaml:"name"`
+	Type    string            `yaml:"type"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+	SMTP    *SMTPConfig       `yaml:"smtp,omitempty"`
+	Events  []string          `yaml:"events,omitempty"`
+}
+
+type SMTPConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	StartTLS bool     `yaml:"starttls"`
+}
+
+// DefaultWebhookBody is the JSON payload sent by generic webhook sinks without a
+// custom body. ${EVENT}, ${MESSAGE}, ${NODE}, ${PRIMARY}, ${CLUSTER} and
+// ${TIMESTAMP} are substituted when the event fires.
+const DefaultWebhookBody = `{"event":"${EVENT}","message":"${MESSAGE}","node":"${NODE}","primary":"${PRIMARY}","cluster":"${CLUSTER}","timestamp":"${TIMESTAMP}"}`
+
+// NotificationEvents lists the failover events that can be routed to notification sinks
+var NotificationEvents = []string{"warning", "primary_down", "promoted", "promotion_failed"}
+
+// Accepts reports whether the sink subscribes to the given event. Sinks without
+// an explicit event filter receive every event.
+func (s NotificationSink) Accepts(event string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
 }
 
 type Config struct {
-	Primary    Primary     `yaml:"primary"`
-	Replicas   []Replica   `yaml:"replicas"`
-	Options    Options     `yaml:"options"`
-	Monitoring *Monitoring `yaml:"monitoring,omitempty"`
+	Cluster       Cluster        `yaml:"cluster,omitempty"`
+	Primary       Primary        `yaml:"primary"`
+	Replicas      []Replica      `yaml:"replicas"`
+	Options       Options        `yaml:"options"`
+	Monitoring    *Monitoring    `yaml:"monitoring,omitempty"`
+	Notifications *Notifications `yaml:"notifications,omitempty"`
+	Routing       *Routing       `yaml:"routing,omitempty"`
+	Pooling       *Pooling       `yaml:"pooling,omitempty"`
+	Backup        *Backup        `yaml:"backup,omitempty"`
+	// LogicalSubscribers receive tables through logical replication
+	LogicalSubscribers []LogicalSubscriber `yaml:"logical_subscribers,omitempty"`
 }
 
-func Parse(filename string) (*Config, error) {
-	var config Config
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+// HealthUnitName returns the systemd unit name (without suffix) of the health check.
+// This and the other per-cluster names below are namespaced by Cluster.Name so
+// several clusters can share a host.
+func (c *Config) HealthUnitName() string {
+	if c.Cluster.Name == "" {
+		return "ha-postgres-health"
+	}
+	return fmt.Sprintf("ha-syncgen-%s-health", c.Cluster.Name)
+}
+
+// RoleUnitName returns the systemd unit name (without suffix) of the role check
+// HAProxy queries on every node
+func (c *Config) RoleUnitName() string {
+	if c.Cluster.Name == "" {
+		return "ha-postgres-role"
 	}
+	return fmt.Sprintf("ha-syncgen-%s-role", c.Cluster.Name)
+}
 
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, err
+// PgBouncerUnitName returns the systemd unit name (without suffix) of the
+// PgBouncer on every node
+func (c *Config) PgBouncerUnitName() string {
+	if c.Cluster.Name == "" {
+		return "ha-postgres-pgbouncer"
+	}
+	return fmt.Sprintf("ha-syncgen-%s-pgbouncer", c.Cluster.Name)
+}
+
+// BackupUnitName returns the systemd unit name (without suffix) of the
+// scheduled backups
+func (c *Config) BackupUnitName() string {
+	if c.Cluster.Name == "" {
+		return "ha-postgres-backup"
+	}
+	return fmt.Sprintf("ha-syncgen-%s-backup", c.Cluster.Name)
+}
+
+// BackupConfigName returns the file name (without extension) the backup tool's
+// configuration is installed under on every node
+func (c *Config) BackupConfigName() string {
+	if c.Cluster.Name == "" {
+		return "ha-syncgen"
+	}
+	return fmt.Sprintf("ha-syncgen-%s", c.Cluster.Name)
+}
+
+// VIPInstanceName returns the Keepalived VRRP instance name
+func (c *Config) VIPInstanceName() string {
+	if c.Cluster.Name == "" {
+		return "ha_syncgen"
+	}
+	return fmt.Sprintf("ha_syncgen_%s", c.Cluster.Name)
+}
+
+// LogDirectory returns the directory generated scripts log to on the target hosts
+func (c *Config) LogDirectory() string {
+	if c.Cluster.Name == "" {
+		return "/var/log/ha-syncgen"
+	}
+	return fmt.Sprintf("/var/log/ha-syncgen/%s", c.Cluster.Name)
+}
+
+// InstallDirectory returns the directory generated scripts are installed to on
+// the target hosts
+func (c *Config) InstallDirectory() string {
+	if c.Cluster.Name == "" {
+		return "/opt/ha-syncgen"
+	}
+	return fmt.Sprintf("/opt/ha-syncgen/%s", c.Cluster.Name)
+}
+
+// ApplicationName returns the application_name a replica uses in primary_conninfo
+func (c *Config) ApplicationName(replica Replica) string {
+	if c.Cluster.Name == "" {
+		return replica.ReplicationSlot
+	}
+	return fmt.Sprintf("%s_%s", c.Cluster.Name, replica.ReplicationSlot)
+}
+
+// SyncReplicas returns the replicas with sync_mode 'sync'
+func (c *Config) SyncReplicas() []Replica {
+	var replicas []Replica
+	for _, replica := range c.Replicas {
+		if replica.SyncMode == "sync" {
+			replicas = append(replicas, replica)
+		}
+	}
+	return replicas
+}
+
+// SynchronousStandbyNames returns the primary's synchronous_standby_names
+// value, e.g. FIRST 1 ("replica_1", "replica_2"), or "" if no replica is sync
+func (c *Config) SynchronousStandbyNames() string {
+	replicas := c.SyncReplicas()
+	if len(replicas) == 0 {
+		return ""
+	}
+	names := make([]string, len(replicas))
+	for i, replica := range replicas {
+		names[i] = fmt.Sprintf("%q", c.ApplicationName(replica))
+	}
+	return fmt.Sprintf("%s %d (%s)", strings.ToUpper(c.Options.SyncQuorum.Method), c.Options.SyncQuorum.NumSync, strings.Join(names, ", "))
+}
+
+// Downstreams returns the replicas that stream from upstream, which is a replica
+// host or "" for the primary
+func (c *Config) Downstreams(upstream string) []Replica {
+	var replicas []Replica
+	for _, replica := range c.Replicas {
+		if replica.Upstream == upstream {
+			replicas = append(replicas, replica)
+		}
 	}
+	return replicas
+}
 
-	if err := Validate(&config); err != nil {
-		return nil, fmt.Errorf("validation error:\n%w", err)
+// UpstreamAddress returns the host and port a replica streams from
+func (c *Config) UpstreamAddress(replica Replica) (string, int) {
+	for _, upstream := range c.Replicas {
+		if replica.Upstream != "" && upstream.Host == replica.Upstream {
+			return upstream.Host, upstream.Port
+		}
+	}
+	return c.Primary.Host, c.Primary.Port
+}
+
+// ClusterTags returns the cluster identity as key:value tags for monitoring integrations
+func (c *Config) ClusterTags() []string {
+	var tags []string
+	if c.Cluster.Name != "" {
+		tags = append(tags, "cluster:"+c.Cluster.Name)
+	}
+	if c.Cluster.Environment != "" {
+		tags = append(tags, "env:"+c.Cluster.Environment)
+	}
+	keys := make([]string, 0, len(c.Cluster.Labels))
+	for key := range c.Cluster.Labels {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		tags = append(tags, fmt.Sprintf("%s:%s", key, c.Cluster.Labels[key]))
+	}
+	return tags
+}
+
+// DatadogEnabled reports whether the Datadog integration is configured and enabled
+func (c *Config) DatadogEnabled() bool {
+	return c.Monitoring != nil && c.Monitoring.Datadog.Enabled
+}
+
+// HAProxyEnabled reports whether HAProxy routing is configured and enabled
+func (c *Config) HAProxyEnabled() bool {
+	return c.Routing != nil && c.Routing.HAProxy.Enabled
+}
 
-	return &config, nil
+// VIPEnabled reports whether a Keepalived virtual IP is configured
+func (c *Config) VIPEnabled() bool {
+	return c.Routing != nil && c.Routing.VIP != nil
+}
+
+// PgBouncerEnabled reports whether PgBouncer pooling is configured and enabled
+func (c *Config) PgBouncerEnabled() bool {
+	return c.Pooling != nil && c.Pooling.PgBouncer.Enabled
+}
+
+// ArchiveEnabled reports whether WAL archiving is on. It is on unless
+// options.archive.enabled is false.
+func (c *Config) ArchiveEnabled() bool {
+	return c.Options.Archive.Enabled == nil || *c.Options.Archive.Enabled
+}
+
+// BackupEnabled reports whether a backup tool is configured
+func (c *Config) BackupEnabled() bool {
+	return c.Backup != nil && c.Backup.Tool != "none"
+}
+
+// Parse reads and validates a single-cluster configuration file
+func Parse(filename string) (*Config, error) {
+	doc, err := Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Cluster("")
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -62,12 +63,16 @@ options:
 					},
 				},
 				Options: Options{
-					PromoteOnFailure:  true,
-					WalLevel:          "replica",
-					MaxWalSenders:     3,
-					WalKeepSize:       "1GB",
-					HotStandby:        true,
-					SynchronousCommit: "on",
+					PromoteOnFailure:    true,
+					WalLevel:            "replica",
+					MaxWalSenders:       3,
+					MaxReplicationSlots: 3,
+					WalKeepSize:         "1GB",
+					SlotWalWarningSize:  "10GB",
+					HotStandby:          true,
+					SynchronousCommit:   "on",
+					SyncQuorum:          SyncQuorum{Method: "first", NumSync: 1},
+					Archive:             ArchiveOptions{Enabled: boolPtr(true), Directory: "/var/lib/postgresql/archive"},
 				},
 			},
 			wantErr: false,
@@ -127,12 +132,16 @@ options:
 					{Host: "192.168.1.103", Port: 5433, ReplicationSlot: "replica_3", SyncMode: "async", DbUser: "postgres", DbPassword: "secret123"},
 				},
 				Options: Options{
-					PromoteOnFailure:  false,
-					WalLevel:          "logical",
-					MaxWalSenders:     5,
-					WalKeepSize:       "2GB",
-					HotStandby:        true,
-					SynchronousCommit: "remote_apply",
+					PromoteOnFailure:    false,
+					WalLevel:            "logical",
+					MaxWalSenders:       5,
+					MaxReplicationSlots: 5,
+					WalKeepSize:         "2GB",
+					SlotWalWarningSize:  "10GB",
+					HotStandby:          true,
+					SynchronousCommit:   "remote_apply",
+					SyncQuorum:          SyncQuorum{Method: "first", NumSync: 1},
+					Archive:             ArchiveOptions{Enabled: boolPtr(true), Directory: "/opt/postgresql/archive"},
 				},
 			},
 			wantErr: false,
@@ -173,12 +182,16 @@ replicas:
 					},
 				},
 				Options: Options{
-					PromoteOnFailure:  false,
-					WalLevel:          "replica",
-					MaxWalSenders:     3,
-					WalKeepSize:       "1GB",
-					HotStandby:        false,
-					SynchronousCommit: "on",
+					PromoteOnFailure:    false,
+					WalLevel:            "replica",
+					MaxWalSenders:       3,
+					MaxReplicationSlots: 3,
+					WalKeepSize:         "1GB",
+					SlotWalWarningSize:  "10GB",
+					HotStandby:          false,
+					SynchronousCommit:   "on",
+					SyncQuorum:          SyncQuorum{Method: "first", NumSync: 1},
+					Archive:             ArchiveOptions{Enabled: boolPtr(true), Directory: "/var/lib/postgresql/archive"},
 				},
 				Monitoring: nil,
 			},
@@ -317,9 +330,9 @@ replicas:
 			wantErr: true,
 		},
 		{
-			name: "valid config with unicode characters",
+			name: "valid config with punycode host names",
 			yaml: `primary:
-  host: "测试服务器"
+  host: "xn--zfru1ggxtv1f4j5b"
   port: 5432
   data_directory: /var/lib/postgresql/data
   db_name: postgres
@@ -328,7 +341,7 @@ replicas:
   replication_user: replicator
   replication_password: password
 replicas:
-  - host: "副本服务器1"
+  - host: "xn--1-ui8azfu2mx7zkfa"
     port: 5432
     replication_slot: "replica_slot_1"
     sync_mode: async
@@ -337,7 +350,7 @@ replicas:
 `,
 			want: &Config{
 				Primary: Primary{
-					Host:                "测试服务器",
+					Host:                "xn--zfru1ggxtv1f4j5b",
 					Port:                5432,
 					DataDirectory:       "/var/lib/postgresql/data",
 					DbName:              "postgres",
@@ -348,7 +361,7 @@ replicas:
 				},
 				Replicas: []Replica{
 					{
-						Host:            "副本服务器1",
+						Host:            "xn--1-ui8azfu2mx7zkfa",
 						Port:            5432,
 						ReplicationSlot: "replica_slot_1",
 						SyncMode:        "async",
@@ -357,12 +370,16 @@ replicas:
 					},
 				},
 				Options: Options{
-					PromoteOnFailure:  false,
-					WalLevel:          "replica",
-					MaxWalSenders:     3,
-					WalKeepSize:       "1GB",
-					HotStandby:        false,
-					SynchronousCommit: "on",
+					PromoteOnFailure:    false,
+					WalLevel:            "replica",
+					MaxWalSenders:       3,
+					MaxReplicationSlots: 3,
+					WalKeepSize:         "1GB",
+					SlotWalWarningSize:  "10GB",
+					HotStandby:          false,
+					SynchronousCommit:   "on",
+					SyncQuorum:          SyncQuorum{Method: "first", NumSync: 1},
+					Archive:             ArchiveOptions{Enabled: boolPtr(true), Directory: "/var/lib/postgresql/archive"},
 				},
 			},
 			wantErr: false,
@@ -841,6 +858,54 @@ monitoring:
     datadog_user_password: valid_password`,
 			wantErr: false,
 		},
+		{
+			name: "Datadog with invalid agent version",
+			yaml: `primary:
+  host: 10.0.0.1
+  db_name: postgres
+  db_user: postgres
+  db_password: change_this_admin_password
+  replication_user: replicator
+  replication_password: secure_password_here
+
+replicas:
+  - host: 10.0.0.2
+    replication_slot: replica_slot_1
+    sync_mode: async
+
+monitoring:
+  datadog:
+    enabled: true
+    api_key: valid_api_key
+    datadog_user_password: valid_password
+    agent_version: latest`,
+			wantErr: true,
+			errMsg:  "monitoring.datadog.agent_version: invalid version 'latest'",
+		},
+		{
+			name: "Datadog with invalid tag",
+			yaml: `primary:
+  host: 10.0.0.1
+  db_name: postgres
+  db_user: postgres
+  db_password: change_this_admin_password
+  replication_user: replicator
+  replication_password: secure_password_here
+
+replicas:
+  - host: 10.0.0.2
+    replication_slot: replica_slot_1
+    sync_mode: async
+
+monitoring:
+  datadog:
+    enabled: true
+    api_key: valid_api_key
+    datadog_user_password: valid_password
+    tags: ["team:db", "bad tag"]`,
+			wantErr: true,
+			errMsg:  "monitoring.datadog.tags[1]",
+		},
 	}
 
 	for _, tt := range tests {
@@ -853,14 +918,971 @@ monitoring:
 				t.Fatalf("Failed to create test file: %v", err)
 			}
 
-			_, err = Parse(tmpFile)
+			cfg, err := Parse(tmpFile)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
+			if !tt.wantErr && cfg != nil {
+				if cfg.Monitoring.Datadog.Site != "datadoghq.com" || cfg.Monitoring.Datadog.AgentVersion == "" {
+					t.Errorf("Datadog defaults not applied: %+v", cfg.Monitoring.Datadog)
+				}
+			}
+
 			if tt.wantErr && err != nil && !strings.Contains(err.Error(), tt.errMsg) {
 				t.Errorf("Parse() error = %v, expected to contain %v", err, tt.errMsg)
 			}
 		})
 	}
 }
+
+func TestNotificationsValidation(t *testing.T) {
+	base := `primary:
+  host: 10.0.0.1
+  db_name: postgres
+  db_user: postgres
+  db_password: password
+  replication_user: replicator
+  replication_password: password
+replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid sinks with defaults",
+			yaml: base + `notifications:
+  sinks:
+    - type: webhook
+      url: https://hooks.example.com/failover
+    - name: ops-slack
+      type: slack
+      url: https://hooks.slack.com/services/T000/B000/XXX
+      events: [primary_down, promoted]
+    - type: email
+      smtp:
+        host: smtp.example.com
+        from: ha@example.com
+        to: [ops@example.com]`,
+			wantErr: false,
+		},
+		{
+			name: "no sinks",
+			yaml: base + `notifications:
+  retries: 2`,
+			wantErr: true,
+			errMsg:  "notifications.sinks requires at least one sink",
+		},
+		{
+			name: "invalid sink type",
+			yaml: base + `notifications:
+  sinks:
+    - type: pager`,
+			wantErr: true,
+			errMsg:  "invalid type 'pager'",
+		},
+		{
+			name: "webhook without url",
+			yaml: base + `notifications:
+  sinks:
+    - type: webhook`,
+			wantErr: true,
+			errMsg:  "notifications.sinks[0].url is required",
+		},
+		{
+			name: "invalid event filter",
+			yaml: base + `notifications:
+  sinks:
+    - type: teams
+      url: https://example.webhook.office.com/x
+      events: [exploded]`,
+			wantErr: true,
+			errMsg:  "invalid event 'exploded'",
+		},
+		{
+			name: "email without recipients",
+			yaml: base + `notifications:
+  sinks:
+    - type: email
+      smtp:
+        host: smtp.example.com
+        from: ha@example.com`,
+			wantErr: true,
+			errMsg:  "notifications.sinks[0].smtp.to requires at least one recipient",
+		},
+		{
+			name: "invalid retry delay",
+			yaml: base + `notifications:
+  retry_delay: soon
+  sinks:
+    - type: slack
+      url: https://hooks.slack.com/services/T000/B000/XXX`,
+			wantErr: true,
+			errMsg:  "notifications.retry_delay",
+		},
+		{
+			name: "negative retries",
+			yaml: base + `notifications:
+  retries: -1
+  sinks:
+    - type: slack
+      url: https://hooks.slack.com/services/T000/B000/XXX`,
+			wantErr: true,
+			errMsg:  "notifications.retries is -1 but must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			tmpFile := filepath.Join(tmpDir, "config.yaml")
+
+			err := os.WriteFile(tmpFile, []byte(tt.yaml), 0644)
+			if err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			cfg, err := Parse(tmpFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("Parse() error = %v, expected to contain %v", err, tt.errMsg)
+				}
+				return
+			}
+
+			n := cfg.Notifications
+			if n.Retries != 3 || n.RetryDelay != "5s" {
+				t.Errorf("retry defaults not applied: retries=%d retry_delay=%q", n.Retries, n.RetryDelay)
+			}
+			if n.Sinks[0].Name != "webhook-1" || n.Sinks[0].Body != DefaultWebhookBody {
+				t.Errorf("webhook defaults not applied: %+v", n.Sinks[0])
+			}
+			if n.Sinks[2].SMTP.Port != 587 {
+				t.Errorf("smtp port default = %d, want 587", n.Sinks[2].SMTP.Port)
+			}
+			if n.Sinks[1].Accepts("warning") || !n.Sinks[1].Accepts("promoted") {
+				t.Errorf("event filter not honoured for %s", n.Sinks[1].Name)
+			}
+		})
+	}
+}
+
+func TestClusterMetadata(t *testing.T) {
+	base := `primary:
+  host: 10.0.0.1
+  db_name: postgres
+  db_user: postgres
+  db_password: password
+  replication_user: replicator
+  replication_password: password
+replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "named cluster with labels",
+			yaml: `cluster:
+  name: orders-prod
+  environment: prod
+  labels:
+    team: payments
+    region: eu-west-1
+` + base,
+			wantErr: false,
+		},
+		{
+			name: "invalid cluster name",
+			yaml: `cluster:
+  name: Orders_Prod
+` + base,
+			wantErr: true,
+			errMsg:  "cluster.name: invalid cluster name 'Orders_Prod'",
+		},
+		{
+			name: "invalid label value",
+			yaml: `cluster:
+  name: orders
+  labels:
+    team: "two words"
+` + base,
+			wantErr: true,
+			errMsg:  "cluster.labels.team",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			tmpFile := filepath.Join(tmpDir, "config.yaml")
+
+			err := os.WriteFile(tmpFile, []byte(tt.yaml), 0644)
+			if err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			cfg, err := Parse(tmpFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("Parse() error = %v, expected to contain %v", err, tt.errMsg)
+				}
+				return
+			}
+
+			if got := cfg.HealthUnitName(); got != "ha-syncgen-orders-prod-health" {
+				t.Errorf("HealthUnitName() = %q", got)
+			}
+			if got := cfg.LogDirectory(); got != "/var/log/ha-syncgen/orders-prod" {
+				t.Errorf("LogDirectory() = %q", got)
+			}
+			if got := cfg.ApplicationName(cfg.Replicas[0]); got != "orders-prod_slot1" {
+				t.Errorf("ApplicationName() = %q", got)
+			}
+			wantTags := []string{"cluster:orders-prod", "env:prod", "region:eu-west-1", "team:payments"}
+			if got := cfg.ClusterTags(); !reflect.DeepEqual(got, wantTags) {
+				t.Errorf("ClusterTags() = %v, want %v", got, wantTags)
+			}
+		})
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	cfg := &Config{
+		Replicas:      []Replica{{Host: "10.0.0.2", ReplicationSlot: "slot1"}},
+		Monitoring:    &Monitoring{Datadog: DatadogConfig{Enabled: true}},
+		Notifications: &Notifications{Sinks: []NotificationSink{{Type: "webhook", URL: "https://example.com"}}},
+	}
+
+	warnings := ApplyDefaults(cfg)
+	if len(warnings) != 1 || warnings[0].Path != "replicas[0].sync_mode" || warnings[0].Severity != SeverityWarning {
+		t.Errorf("ApplyDefaults() warnings = %v, want one warning for replicas[0].sync_mode", warnings)
+	}
+
+	if cfg.Primary.Port != 5432 || cfg.Replicas[0].Port != 5432 || cfg.Replicas[0].SyncMode != "async" {
+		t.Errorf("node defaults not applied: %+v %+v", cfg.Primary, cfg.Replicas[0])
+	}
+	if cfg.Options.WalLevel != "replica" || cfg.Options.MaxWalSenders != 3 || cfg.Options.SynchronousCommit != "on" {
+		t.Errorf("option defaults not applied: %+v", cfg.Options)
+	}
+	if cfg.Monitoring.Datadog.Site != "datadoghq.com" {
+		t.Errorf("Datadog site = %q, want datadoghq.com", cfg.Monitoring.Datadog.Site)
+	}
+	if sink := cfg.Notifications.Sinks[0]; sink.Name != "webhook-1" || sink.Body != DefaultWebhookBody {
+		t.Errorf("sink defaults not applied: %+v", sink)
+	}
+
+	// Defaults never override explicit values
+	cfg.Options.WalLevel = "logical"
+	if warnings := ApplyDefaults(cfg); len(warnings) != 0 || cfg.Options.WalLevel != "logical" {
+		t.Errorf("second ApplyDefaults() changed the config: warnings=%v wal_level=%s", warnings, cfg.Options.WalLevel)
+	}
+}
+
+func TestDefaultReplicationLimitsScale(t *testing.T) {
+	for _, n := range []int{1, 3, 4, 8} {
+		cfg := &Config{
+			Primary: Primary{
+				Host: "10.0.0.1", DbName: "postgres", DbUser: "postgres", DbPassword: "password",
+				ReplicationUser: "replicator", ReplicationPassword: "password",
+			},
+		}
+		for i := 0; i < n; i++ {
+			cfg.Replicas = append(cfg.Replicas, Replica{Host: fmt.Sprintf("10.0.0.%d", i+2), ReplicationSlot: fmt.Sprintf("slot%d", i+1), SyncMode: "async"})
+		}
+		if issues := Check(cfg); len(issues) != 0 {
+			t.Errorf("Check() with %d replicas and default limits = %v, want no issues", n, issues)
+		}
+	}
+}
+
+func TestSemanticWarnings(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			Primary: Primary{
+				Host: "10.0.0.1", DbName: "postgres", DbUser: "postgres", DbPassword: "password",
+				ReplicationUser: "replicator", ReplicationPassword: "password",
+			},
+			Replicas: []Replica{
+				{Host: "10.0.0.2", ReplicationSlot: "slot1", SyncMode: "sync"},
+				{Host: "10.0.0.3", ReplicationSlot: "slot2", SyncMode: "async"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(cfg *Config)
+		wantPath string
+	}{
+		{
+			name:     "sync replica with synchronous_commit off",
+			modify:   func(cfg *Config) { cfg.Options.SynchronousCommit = "off" },
+			wantPath: "replicas[0].sync_mode",
+		},
+		{
+			name:     "wal_level minimal with replicas",
+			modify:   func(cfg *Config) { cfg.Options.WalLevel = "minimal" },
+			wantPath: "options.wal_level",
+		},
+		{
+			name:     "max_wal_senders without headroom",
+			modify:   func(cfg *Config) { cfg.Options.MaxWalSenders = 2 },
+			wantPath: "options.max_wal_senders",
+		},
+		{
+			name: "promote_on_failure with only delayed replicas",
+			modify: func(cfg *Config) {
+				cfg.Options.PromoteOnFailure = true
+				for i := range cfg.Replicas {
+					cfg.Replicas[i].SyncMode = "async"
+					cfg.Replicas[i].RecoveryMinApplyDelay = "1h"
+				}
+			},
+			wantPath: "options.promote_on_failure",
+		},
+		{
+			name: "promote_on_failure with a delayed replica and its cascading replica",
+			modify: func(cfg *Config) {
+				cfg.Options.PromoteOnFailure = true
+				cfg.Replicas[0].SyncMode = "async"
+				cfg.Replicas[0].RecoveryMinApplyDelay = "1h"
+				cfg.Replicas[1].Upstream = "10.0.0.2"
+			},
+			wantPath: "options.promote_on_failure",
+		},
+		{
+			name: "slot WAL warning above max_slot_wal_keep_size",
+			modify: func(cfg *Config) {
+				cfg.Options.MaxSlotWalKeepSize = "8GB"
+				cfg.Options.SlotWalWarningSize = "10GB"
+			},
+			wantPath: "options.slot_wal_warning_size",
+		},
+		{
+			name: "HAProxy read-only port without hot standby",
+			modify: func(cfg *Config) {
+				cfg.Routing = &Routing{HAProxy: HAProxyConfig{Enabled: true}}
+			},
+			wantPath: "options.hot_standby",
+		},
+	}
+
+	if issues := Check(valid()); len(issues) != 0 {
+		t.Fatalf("Check() on a clean config = %v, want no issues", issues)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			issues := Check(cfg)
+			if len(issues) != 1 || issues[0].Path != tt.wantPath || issues[0].Severity != SeverityWarning {
+				t.Fatalf("Check() = %v, want one warning for %s", issues, tt.wantPath)
+			}
+			if err := Validate(cfg); err != nil {
+				t.Errorf("Validate() should ignore warnings, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTopologyValidation(t *testing.T) {
+	base := `primary:
+  host: 10.0.0.1
+  db_name: postgres
+  db_user: postgres
+  db_password: password
+  replication_user: replicator
+  replication_password: password
+`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "IPv6 and DNS hosts",
+			yaml: base + `replicas:
+  - host: "fd00::2"
+    replication_slot: slot1
+    sync_mode: async
+  - host: replica-2.db.example.com
+    replication_slot: slot2
+    sync_mode: async
+`,
+			wantErr: false,
+		},
+		{
+			name: "duplicate replica hosts",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+  - host: 10.0.0.2
+    port: 5433
+    replication_slot: slot2
+    sync_mode: async
+`,
+			wantErr: true,
+			errMsg:  "replicas[1].host '10.0.0.2' is already used by replicas[0]",
+		},
+		{
+			name: "replica on the primary's address",
+			yaml: base + `replicas:
+  - host: 10.0.0.1
+    replication_slot: slot1
+    sync_mode: async
+`,
+			wantErr: true,
+			errMsg:  "replicas[0].host '10.0.0.1' with port 5432 is the primary's address",
+		},
+		{
+			name: "delayed replica",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+    recovery_min_apply_delay: 30min
+`,
+			wantErr: false,
+		},
+		{
+			name: "invalid apply delay",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+    recovery_min_apply_delay: 1 hour
+`,
+			wantErr: true,
+			errMsg:  "invalid delay '1 hour'",
+		},
+		{
+			name: "delayed sync replica",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: sync
+    recovery_min_apply_delay: 1h
+`,
+			wantErr: true,
+			errMsg:  "replicas[0].sync_mode cannot be 'sync' on a delayed replica (recovery_min_apply_delay '1h')",
+		},
+		{
+			name: "sync quorum larger than the sync replicas",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: sync
+  - host: 10.0.0.3
+    replication_slot: slot2
+    sync_mode: async
+options:
+  max_wal_senders: 5
+  sync_quorum:
+    method: any
+    num_sync: 2
+`,
+			wantErr: true,
+			errMsg:  "options.sync_quorum.num_sync is 2 but only 1 replicas have sync_mode 'sync'",
+		},
+		{
+			name: "invalid sync quorum method",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: sync
+options:
+  sync_quorum:
+    method: all
+`,
+			wantErr: true,
+			errMsg:  "options.sync_quorum.method: invalid method 'all'",
+		},
+		{
+			name: "sync cascading replica",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+  - host: 10.0.0.3
+    replication_slot: slot2
+    sync_mode: sync
+    upstream: 10.0.0.2
+`,
+			wantErr: true,
+			errMsg:  "replicas[1].sync_mode cannot be 'sync' on a cascading replica (upstream '10.0.0.2')",
+		},
+		{
+			name: "logical subscribers",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+logical_subscribers:
+  - name: analytics
+    host: 10.0.2.10
+    publications:
+      - name: orders_pub
+        tables: [orders, sales.customers]
+  - name: search
+    host: 10.0.2.11
+    publications:
+      - name: orders_pub
+        tables: [orders, sales.customers]
+      - name: billing_pub
+        schemas: [billing]
+options:
+  wal_level: logical
+  max_wal_senders: 4
+`,
+			wantErr: false,
+		},
+		{
+			name: "logical subscribers without wal_level logical",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+logical_subscribers:
+  - name: analytics
+    host: 10.0.2.10
+    publications:
+      - name: orders_pub
+        tables: [orders]
+options:
+  max_wal_senders: 4
+`,
+			wantErr: true,
+			errMsg:  "options.wal_level is 'replica' but logical_subscribers require 'logical'",
+		},
+		{
+			name: "publication without tables",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+logical_subscribers:
+  - name: analytics
+    host: 10.0.2.10
+    publications:
+      - name: orders_pub
+options:
+  wal_level: logical
+  max_wal_senders: 4
+`,
+			wantErr: true,
+			errMsg:  "logical_subscribers[0].publications[0] must list tables or schemas",
+		},
+		{
+			name: "publication redefined by another subscriber",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+logical_subscribers:
+  - name: analytics
+    host: 10.0.2.10
+    publications:
+      - name: orders_pub
+        tables: [orders]
+  - name: search
+    host: 10.0.2.11
+    publications:
+      - name: orders_pub
+        tables: [customers]
+options:
+  wal_level: logical
+  max_wal_senders: 4
+`,
+			wantErr: true,
+			errMsg:  "logical_subscribers[1].publications[0].name 'orders_pub' is already defined by logical_subscribers[0] with different tables or schemas",
+		},
+		{
+			name: "logical subscribers need WAL senders",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+logical_subscribers:
+  - name: analytics
+    host: 10.0.2.10
+    publications:
+      - name: orders_pub
+        tables: [orders]
+options:
+  wal_level: logical
+  max_wal_senders: 1
+`,
+			wantErr: true,
+			errMsg:  "options.max_wal_senders is 1 but 2 replicas and logical subscribers stream from the primary",
+		},
+		{
+			name: "invalid max_slot_wal_keep_size",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+options:
+  max_slot_wal_keep_size: 10 GB
+`,
+			wantErr: true,
+			errMsg:  "options.max_slot_wal_keep_size: invalid max_slot_wal_keep_size '10 GB'",
+		},
+		{
+			name: "HAProxy ports collide",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+routing:
+  haproxy:
+    enabled: true
+    read_write_port: 6432
+    read_only_port: 6432
+`,
+			wantErr: true,
+			errMsg:  "routing.haproxy.read_only_port is 6432, which is already used by routing.haproxy.read_write_port",
+		},
+		{
+			name: "HAProxy check port on a PostgreSQL port",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    port: 8008
+    replication_slot: slot1
+    sync_mode: async
+routing:
+  haproxy:
+    enabled: true
+`,
+			wantErr: true,
+			errMsg:  "routing.haproxy.check_port is 8008, which is the PostgreSQL port of replicas[0]",
+		},
+		{
+			name: "VIP is a replica address",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+routing:
+  vip:
+    address: 10.0.0.2/24
+    interface: eth0
+`,
+			wantErr: true,
+			errMsg:  "routing.vip.address '10.0.0.2/24' is the address of replicas[0]",
+		},
+		{
+			name: "VIP without an interface",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+routing:
+  vip:
+    address: 10.0.0.100
+`,
+			wantErr: true,
+			errMsg:  "routing.vip.interface is required",
+		},
+		{
+			name: "PgBouncer on a PostgreSQL port",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    port: 6432
+    replication_slot: slot1
+    sync_mode: async
+pooling:
+  pgbouncer:
+    enabled: true
+`,
+			wantErr: true,
+			errMsg:  "pooling.pgbouncer.listen_port is 6432, which is the PostgreSQL port of replicas[0]",
+		},
+		{
+			name: "invalid PgBouncer pool mode",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+pooling:
+  pgbouncer:
+    enabled: true
+    pool_mode: pooled
+`,
+			wantErr: true,
+			errMsg:  "pooling.pgbouncer.pool_mode: invalid pool mode 'pooled'",
+		},
+		{
+			name: "relative PgBouncer binary",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+pooling:
+  pgbouncer:
+    enabled: true
+    binary: pgbouncer
+`,
+			wantErr: true,
+			errMsg:  "pooling.pgbouncer.binary: invalid path 'pgbouncer': must be absolute",
+		},
+		{
+			name: "differential local backups",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+backup:
+  tool: local
+  diff_schedule: daily
+`,
+			wantErr: true,
+			errMsg:  "backup.diff_schedule is set but local backups are always full",
+		},
+		{
+			name: "S3 backup repository without a bucket",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+backup:
+  tool: wal-g
+  repository:
+    type: s3
+    region: us-east-1
+`,
+			wantErr: true,
+			errMsg:  "backup.repository.bucket is required for s3 repositories",
+		},
+		{
+			name: "archiving disabled with a backup tool",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+options:
+  archive:
+    enabled: false
+backup:
+  tool: pgbackrest
+`,
+			wantErr: true,
+			errMsg:  "options.archive.enabled is false but backup.tool 'pgbackrest' needs WAL archiving",
+		},
+		{
+			name: "archive command without %p",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+options:
+  archive:
+    command: /usr/local/bin/ship-wal
+`,
+			wantErr: true,
+			errMsg:  "options.archive.command must contain %p",
+		},
+		{
+			name: "non-ASCII host",
+			yaml: base + `replicas:
+  - host: "副本服务器1"
+    replication_slot: slot1
+    sync_mode: async
+`,
+			wantErr: true,
+			errMsg:  "replicas[0].host: invalid host '副本服务器1'",
+		},
+		{
+			name: "invalid host",
+			yaml: base + `replicas:
+  - host: "10.0.0.0/24"
+    replication_slot: slot1
+    sync_mode: async
+`,
+			wantErr: true,
+			errMsg:  "replicas[0].host: invalid host '10.0.0.0/24'",
+		},
+		{
+			name: "malformed IPv4 address",
+			yaml: base + `replicas:
+  - host: 10.0.0.300
+    replication_slot: slot1
+    sync_mode: async
+`,
+			wantErr: true,
+			errMsg:  "not a valid IP address",
+		},
+		{
+			name: "too few WAL senders",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+  - host: 10.0.0.3
+    replication_slot: slot2
+    sync_mode: async
+options:
+  max_wal_senders: 1
+`,
+			wantErr: true,
+			errMsg:  "options.max_wal_senders is 1 but 2 replicas stream from the primary",
+		},
+		{
+			name: "too few replication slots",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+  - host: 10.0.0.3
+    replication_slot: slot2
+    sync_mode: async
+options:
+  max_replication_slots: 1
+`,
+			wantErr: true,
+			errMsg:  "options.max_replication_slots is 1 but 2 replicas each need a slot on the primary",
+		},
+		{
+			name: "cascading replicas",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+  - host: 10.0.0.3
+    replication_slot: slot2
+    sync_mode: async
+    upstream: 10.0.0.2
+  - host: 10.0.0.4
+    replication_slot: slot3
+    sync_mode: async
+    upstream: 10.0.0.3
+`,
+			wantErr: false,
+		},
+		{
+			name: "unknown upstream",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+    upstream: 10.0.0.9
+`,
+			wantErr: true,
+			errMsg:  "replicas[0].upstream '10.0.0.9' is not the host of a configured replica",
+		},
+		{
+			name: "primary as upstream",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+    upstream: 10.0.0.1
+`,
+			wantErr: true,
+			errMsg:  "replicas[0].upstream '10.0.0.1' is the primary",
+		},
+		{
+			name: "replica as its own upstream",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+    upstream: 10.0.0.2
+`,
+			wantErr: true,
+			errMsg:  "replicas[0].upstream '10.0.0.2' is the replica itself",
+		},
+		{
+			name: "upstream cycle",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+    upstream: 10.0.0.4
+  - host: 10.0.0.3
+    replication_slot: slot2
+    sync_mode: async
+    upstream: 10.0.0.2
+  - host: 10.0.0.4
+    replication_slot: slot3
+    sync_mode: async
+    upstream: 10.0.0.3
+`,
+			wantErr: true,
+			errMsg:  "replicas[0].upstream forms a cycle: 10.0.0.2 -> 10.0.0.4 -> 10.0.0.3 -> 10.0.0.2",
+		},
+		{
+			name: "too few WAL senders on an upstream replica",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+  - host: 10.0.0.3
+    replication_slot: slot2
+    sync_mode: async
+    upstream: 10.0.0.2
+  - host: 10.0.0.4
+    replication_slot: slot3
+    sync_mode: async
+    upstream: 10.0.0.2
+options:
+  max_wal_senders: 1
+`,
+			wantErr: true,
+			errMsg:  "options.max_wal_senders is 1 but 2 replicas stream from replica 10.0.0.2",
+		},
+		{
+			name: "remote_apply without a sync replica",
+			yaml: base + `replicas:
+  - host: 10.0.0.2
+    replication_slot: slot1
+    sync_mode: async
+options:
+  synchronous_commit: remote_apply
+`,
+			wantErr: true,
+			errMsg:  "options.synchronous_commit is 'remote_apply' but no replica has sync_mode 'sync'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(tmpFile, []byte(tt.yaml), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			_, err := Parse(tmpFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Parse() error = %v, expected to contain %v", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
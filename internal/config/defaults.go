@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"path"
+)
+
+// ApplyDefaults fills in every unset field that has a default. It returns a
+// warning for defaults that are likely to surprise, such as a replica silently
+// becoming asynchronous. Invalid values are left alone for validation to report.
+func ApplyDefaults(cfg *Config) []*ValidationError {
+	var warnings []*ValidationError
+
+	primary := &cfg.Primary
+	if primary.Port <= 0 {
+		primary.Port = 5432
+	}
+	if primary.DataDirectory == "" {
+		primary.DataDirectory = "/var/lib/postgresql/data"
+	}
+
+	for i := range cfg.Replicas {
+		replica := &cfg.Replicas[i]
+		if replica.Port <= 0 {
+			replica.Port = 5432 // Default PostgreSQL port
+		}
+		if replica.SyncMode == "" {
+			warnings = append(warnings, fieldWarningf(fmt.Sprintf("replicas[%d].sync_mode", i), "is not set, defaulting to 'async'"))
+			replica.SyncMode = "async"
+		}
+	}
+
+	options := &cfg.Options
+	if options.WalLevel == "" {
+		options.WalLevel = "replica"
+	}
+	if options.MaxWalSenders <= 0 {
+		// One sender per stream plus headroom, and never below PostgreSQL's old default
+		options.MaxWalSenders = max(3, len(cfg.Replicas)+len(cfg.LogicalSubscribers)+replicationHeadroom)
+	}
+	if options.MaxReplicationSlots <= 0 {
+		options.MaxReplicationSlots = len(cfg.Replicas) + len(cfg.LogicalSubscribers) + 2
+	}
+	if options.WalKeepSize == "" {
+		options.WalKeepSize = "1GB"
+	}
+	if options.SlotWalWarningSize == "" {
+		options.SlotWalWarningSize = "10GB"
+	}
+	if options.SynchronousCommit == "" {
+		options.SynchronousCommit = "on"
+	}
+	if options.SyncQuorum.Method == "" {
+		options.SyncQuorum.Method = "first"
+	}
+	if options.SyncQuorum.NumSync <= 0 {
+		options.SyncQuorum.NumSync = 1
+	}
+	if options.Archive.Enabled == nil {
+		enabled := true
+		options.Archive.Enabled = &enabled
+	}
+	if options.Archive.Directory == "" && primary.DataDirectory != "" {
+		options.Archive.Directory = path.Join(path.Dir(primary.DataDirectory), "archive")
+	}
+
+	for i := range cfg.LogicalSubscribers {
+		subscriber := &cfg.LogicalSubscribers[i]
+		if subscriber.Port <= 0 {
+			subscriber.Port = 5432
+		}
+		if subscriber.DbName == "" {
+			subscriber.DbName = primary.DbName
+		}
+		if subscriber.Subscription.CopyData == nil {
+			copyData := true
+			subscriber.Subscription.CopyData = &copyData
+		}
+	}
+
+	if cfg.Monitoring != nil {
+		datadog := &cfg.Monitoring.Datadog
+		if datadog.Site == "" {
+			datadog.Site = "datadoghq.com"
+		}
+		if datadog.AgentVersion == "" {
+			datadog.AgentVersion = "7.52.1" // Pinned so every node runs the same agent
+		}
+	}
+
+	if cfg.Routing != nil {
+		haproxy := &cfg.Routing.HAProxy
+		if haproxy.Bind == "" {
+			haproxy.Bind = "*"
+		}
+		if haproxy.ReadWritePort <= 0 {
+			haproxy.ReadWritePort = 5000
+		}
+		if haproxy.ReadOnlyPort <= 0 {
+			haproxy.ReadOnlyPort = 5001
+		}
+		if haproxy.CheckPort <= 0 {
+			haproxy.CheckPort = 8008
+		}
+		if haproxy.CheckInterval == "" {
+			haproxy.CheckInterval = "3s"
+		}
+		if vip := cfg.Routing.VIP; vip != nil && vip.RouterID == 0 {
+			vip.RouterID = 51
+		}
+	}
+
+	if cfg.Pooling != nil {
+		pgbouncer := &cfg.Pooling.PgBouncer
+		if pgbouncer.ListenPort <= 0 {
+			pgbouncer.ListenPort = 6432
+		}
+		if pgbouncer.PoolMode == "" {
+			pgbouncer.PoolMode = "transaction"
+		}
+		if pgbouncer.DefaultPoolSize <= 0 {
+			pgbouncer.DefaultPoolSize = 20
+		}
+		if pgbouncer.MaxClientConn <= 0 {
+			pgbouncer.MaxClientConn = 100
+		}
+		if pgbouncer.Binary == "" {
+			pgbouncer.Binary = "/usr/sbin/pgbouncer"
+		}
+	}
+
+	if backup := cfg.Backup; backup != nil {
+		if backup.Tool == "" {
+			backup.Tool = "none"
+		}
+		if backup.Stanza == "" {
+			backup.Stanza = "main"
+			if cfg.Cluster.Name != "" {
+				backup.Stanza = cfg.Cluster.Name
+			}
+		}
+		if backup.Repository.Type == "" {
+			backup.Repository.Type = "posix"
+		}
+		if backup.Repository.Path == "" && backup.Repository.Type == "posix" {
+			backup.Repository.Path = "/var/lib/pgbackup"
+		}
+		if backup.FullSchedule == "" {
+			backup.FullSchedule = "Sun *-*-* 01:00:00"
+		}
+		if backup.RetentionFull <= 0 {
+			backup.RetentionFull = 2
+		}
+	}
+
+	if notifications := cfg.Notifications; notifications != nil {
+		if notifications.Retries == 0 {
+			notifications.Retries = 3
+		}
+		if notifications.RetryDelay == "" {
+			notifications.RetryDelay = "5s"
+		}
+		for i := range notifications.Sinks {
+			sink := &notifications.Sinks[i]
+			if sink.Name == "" {
+				sink.Name = fmt.Sprintf("%s-%d", sink.Type, i+1)
+			}
+			if sink.Type == "webhook" && sink.Body == "" {
+				sink.Body = DefaultWebhookBody
+			}
+			if sink.SMTP != nil && sink.SMTP.Port <= 0 {
+				sink.SMTP.Port = 587 // Default submission port
+			}
+		}
+	}
+
+	return warnings
+}
@@ -0,0 +1,333 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a parsed configuration file. It describes either a single cluster
+// or, when it has a top-level `clusters:` map, several clusters that inherit from
+// a shared `defaults:` section.
+type Document struct {
+	filename string
+	root     *yaml.Node
+	// files records which file each node was read from, so validation errors
+	// can point into the right file once overlays have been merged
+	files map[*yaml.Node]string
+
+	// Strict makes validation warnings fail like errors
+	Strict   bool
+	warnings []*ValidationError
+}
+
+// Load reads a configuration file without decoding or validating it
+func Load(filename string) (*Document, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return LoadBytes(filename, data)
+}
+
+// LoadBytes parses configuration data without decoding or validating it.
+// filename is only used in error positions.
+func LoadBytes(filename string, data []byte) (*Document, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if len(doc.Content) > 0 {
+		root = resolveAlias(doc.Content[0])
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: top level of the config file must be a mapping", filename)
+	}
+	d := &Document{filename: filename, root: root, files: make(map[*yaml.Node]string)}
+	d.recordFile(root, filename)
+	return d, nil
+}
+
+func (d *Document) recordFile(node *yaml.Node, filename string) {
+	d.files[node] = filename
+	for _, child := range node.Content {
+		d.recordFile(child, filename)
+	}
+}
+
+// Overlay merges another configuration file over the document. Maps are merged,
+// replicas are merged by host and an explicit null deletes a key. Overlays are
+// applied in the order they are added, before any cluster is decoded.
+func (d *Document) Overlay(filename string) error {
+	overlay, err := Load(filename)
+	if err != nil {
+		return err
+	}
+	for node, file := range overlay.files {
+		d.files[node] = file
+	}
+	d.root = d.mergeNodes(d.root, overlay.root)
+	return nil
+}
+
+// IsMultiCluster reports whether the document defines a `clusters:` map
+func (d *Document) IsMultiCluster() bool {
+	return mappingValue(d.root, "clusters") != nil
+}
+
+// ClusterNames returns the cluster names in the order they appear in the file.
+// Single-cluster documents return their cluster.name, which may be empty.
+func (d *Document) ClusterNames() []string {
+	clusters := mappingValue(d.root, "clusters")
+	if clusters == nil {
+		name := ""
+		if cluster := mappingValue(d.root, "cluster"); cluster != nil {
+			if n := mappingValue(cluster, "name"); n != nil {
+				name = n.Value
+			}
+		}
+		return []string{name}
+	}
+	var names []string
+	for i := 0; i+1 < len(clusters.Content); i += 2 {
+		names = append(names, clusters.Content[i].Value)
+	}
+	return names
+}
+
+// Cluster decodes, defaults and validates a single cluster. For multi-cluster
+// documents the cluster's settings are deep-merged over `defaults:` and name is
+// required; for single-cluster documents name may be empty.
+func (d *Document) Cluster(name string) (*Config, error) {
+	d.warnings = nil
+	return d.cluster(name)
+}
+
+// Warnings returns the validation warnings from the last call to Cluster or All.
+// In strict mode warnings are returned as errors instead.
+func (d *Document) Warnings() []*ValidationError {
+	return d.warnings
+}
+
+func (d *Document) cluster(name string) (*Config, error) {
+	if !d.IsMultiCluster() {
+		if name != "" && name != d.ClusterNames()[0] {
+			return nil, fmt.Errorf("cluster '%s' not found in %s", name, d.filename)
+		}
+		return d.decodeConfig(d.root, "")
+	}
+
+	for i := 0; i+1 < len(d.root.Content); i += 2 {
+		if key := d.root.Content[i].Value; key != "clusters" && key != "defaults" {
+			return nil, fmt.Errorf("%s: top-level key '%s' is not allowed alongside clusters; move it under defaults or a cluster", d.filename, key)
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%s defines multiple clusters (%s); select one with --cluster or use --all",
+			d.filename, strings.Join(d.ClusterNames(), ", "))
+	}
+	clusterNode := mappingValue(mappingValue(d.root, "clusters"), name)
+	if clusterNode == nil {
+		return nil, fmt.Errorf("cluster '%s' not found in %s (available: %s)",
+			name, d.filename, strings.Join(d.ClusterNames(), ", "))
+	}
+
+	merged := d.mergeNodes(mappingValue(d.root, "defaults"), clusterNode)
+	return d.decodeConfig(merged, name)
+}
+
+// All decodes and validates every cluster in the document
+func (d *Document) All() ([]*Config, error) {
+	d.warnings = nil
+	var configs []*Config
+	var errs []error
+	for _, name := range d.ClusterNames() {
+		cfg, err := d.cluster(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		configs = append(configs, cfg)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return configs, nil
+}
+
+// decodeConfig turns a merged mapping node into a validated Config. A non-empty
+// clusterName comes from a multi-cluster document and prefixes validation errors.
+func (d *Document) decodeConfig(node *yaml.Node, clusterName string) (*Config, error) {
+	var config Config
+	if err := node.Decode(&config); err != nil {
+		return nil, prefixError(clusterName, err)
+	}
+
+	if clusterName != "" {
+		if config.Cluster.Name != "" && config.Cluster.Name != clusterName {
+			return nil, fmt.Errorf("%s: cluster.name '%s' does not match its key in clusters", clusterName, config.Cluster.Name)
+		}
+		config.Cluster.Name = clusterName
+	}
+
+	var errs []error
+	var warnings []*ValidationError
+	for _, issue := range Check(&config) {
+		issue.Cluster = clusterName
+		if located := locate(node, issue.Path); located != nil && located.Line > 0 {
+			issue.File, issue.Line, issue.Column = d.files[located], located.Line, located.Column
+		}
+		if issue.Severity == SeverityError || d.Strict {
+			errs = append(errs, issue)
+		} else {
+			warnings = append(warnings, issue)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("validation error:\n%w", errors.Join(errs...))
+	}
+	d.warnings = append(d.warnings, warnings...)
+	return &config, nil
+}
+
+func prefixError(clusterName string, err error) error {
+	if clusterName == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", clusterName, err)
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return resolveAlias(node.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// locate returns the node at a validation path such as "replicas[1].host". If
+// the field is missing, the closest parent that exists is returned instead.
+func locate(root *yaml.Node, path string) *yaml.Node {
+	node := root
+	for _, segment := range strings.Split(path, ".") {
+		key, indexes, _ := strings.Cut(segment, "[")
+		next := mappingValue(node, key)
+		if next == nil {
+			return node
+		}
+		node = next
+		if indexes == "" {
+			continue
+		}
+		for _, index := range strings.Split(strings.TrimSuffix(indexes, "]"), "][") {
+			i, err := strconv.Atoi(index)
+			if err != nil || node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+				return node
+			}
+			node = resolveAlias(node.Content[i])
+		}
+	}
+	return node
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// mergeKeys lists the sequences that overlays merge item by item, matched on
+// the given field, instead of replacing them wholesale
+var mergeKeys = map[string]string{
+	"replicas": "host",
+}
+
+// mergeNodes deep-merges overlay onto base and returns a new node. Mappings are
+// merged key by key and a null value deletes the key; sequences listed in
+// mergeKeys are merged item by item; any other overlay value replaces the base
+// value. An empty overlay keeps the base. Neither input is modified.
+func (d *Document) mergeNodes(base, overlay *yaml.Node) *yaml.Node {
+	base, overlay = resolveAlias(base), resolveAlias(overlay)
+	if base == nil {
+		return overlay
+	}
+	if overlay == nil || isNull(overlay) {
+		return base
+	}
+	if base.Kind != yaml.MappingNode || overlay.Kind != yaml.MappingNode {
+		return overlay
+	}
+
+	merged := *base
+	merged.Content = append([]*yaml.Node(nil), base.Content...)
+	d.files[&merged] = d.files[base]
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], resolveAlias(overlay.Content[i+1])
+		index := -1
+		for j := 0; j+1 < len(merged.Content); j += 2 {
+			if merged.Content[j].Value == key.Value {
+				index = j
+				break
+			}
+		}
+
+		switch {
+		case isNull(value):
+			if index >= 0 {
+				merged.Content = append(merged.Content[:index], merged.Content[index+2:]...)
+			}
+		case index < 0:
+			merged.Content = append(merged.Content, key, value)
+		case mergeKeys[key.Value] != "":
+			merged.Content[index+1] = d.mergeSequences(merged.Content[index+1], value, mergeKeys[key.Value])
+		default:
+			merged.Content[index+1] = d.mergeNodes(merged.Content[index+1], value)
+		}
+	}
+	return &merged
+}
+
+// mergeSequences merges two sequences of mappings, matching items on field.
+// Matching items are deep-merged in place and new items are appended.
+func (d *Document) mergeSequences(base, overlay *yaml.Node, field string) *yaml.Node {
+	base = resolveAlias(base)
+	if base.Kind != yaml.SequenceNode || overlay.Kind != yaml.SequenceNode {
+		return overlay
+	}
+
+	merged := *base
+	merged.Content = append([]*yaml.Node(nil), base.Content...)
+	d.files[&merged] = d.files[base]
+	for _, item := range overlay.Content {
+		item = resolveAlias(item)
+		id := mappingValue(item, field)
+		matched := false
+		for j, existing := range merged.Content {
+			if existingID := mappingValue(existing, field); id != nil && existingID != nil && existingID.Value == id.Value {
+				merged.Content[j] = d.mergeNodes(existing, item)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			merged.Content = append(merged.Content, item)
+		}
+	}
+	return &merged
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
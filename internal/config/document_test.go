@@ -0,0 +1,381 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const multiClusterYAML = `defaults:
+  primary:
+    data_directory: /var/lib/postgresql/data
+    db_name: postgres
+    db_user: postgres
+    db_password: password
+    replication_user: replicator
+    replication_password: password
+  options:
+    wal_keep_size: 2GB
+    hot_standby: true
+clusters:
+  east:
+    cluster:
+      environment: prod
+    primary:
+      host: 10.1.0.1
+    replicas:
+      - host: 10.1.0.2
+        replication_slot: east_1
+        sync_mode: async
+    options:
+      wal_keep_size: 4GB
+  west:
+    primary:
+      host: 10.2.0.1
+      replication_password: west_password
+    replicas:
+      - host: 10.2.0.2
+        replication_slot: west_1
+        sync_mode: async
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	tmpFile := filepath.Join(t.TempDir(), "clusters.yaml")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	return tmpFile
+}
+
+func TestMultiClusterDefaultsAreDeepMerged(t *testing.T) {
+	doc, err := Load(writeConfig(t, multiClusterYAML))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !doc.IsMultiCluster() {
+		t.Fatal("IsMultiCluster() = false, want true")
+	}
+	if got := strings.Join(doc.ClusterNames(), ","); got != "east,west" {
+		t.Errorf("ClusterNames() = %s, want east,west", got)
+	}
+
+	configs, err := doc.All()
+	if err != nil {
+		t.Fatalf("All() failed: %v", err)
+	}
+	east, west := configs[0], configs[1]
+
+	if east.Cluster.Name != "east" || east.Cluster.Environment != "prod" {
+		t.Errorf("east cluster metadata = %+v", east.Cluster)
+	}
+	if east.Options.WalKeepSize != "4GB" || !east.Options.HotStandby {
+		t.Errorf("east options not merged over defaults: %+v", east.Options)
+	}
+	if east.Primary.Host != "10.1.0.1" || east.Primary.ReplicationPassword != "password" {
+		t.Errorf("east primary not merged over defaults: %+v", east.Primary)
+	}
+	if west.Options.WalKeepSize != "2GB" {
+		t.Errorf("west wal_keep_size = %s, want default 2GB", west.Options.WalKeepSize)
+	}
+	if west.Primary.ReplicationPassword != "west_password" || west.Primary.DbName != "postgres" {
+		t.Errorf("west primary not merged over defaults: %+v", west.Primary)
+	}
+}
+
+func TestMultiClusterSelection(t *testing.T) {
+	doc, err := Load(writeConfig(t, multiClusterYAML))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if _, err := doc.Cluster(""); err == nil || !strings.Contains(err.Error(), "select one with --cluster") {
+		t.Errorf("Cluster(\"\") error = %v, want cluster selection error", err)
+	}
+	if _, err := doc.Cluster("north"); err == nil || !strings.Contains(err.Error(), "cluster 'north' not found") {
+		t.Errorf("Cluster(\"north\") error = %v, want not found error", err)
+	}
+	if _, err := Parse(writeConfig(t, multiClusterYAML)); err == nil {
+		t.Error("Parse() of a multi-cluster file without selection should fail")
+	}
+	cfg, err := doc.Cluster("west")
+	if err != nil {
+		t.Fatalf("Cluster(\"west\") failed: %v", err)
+	}
+	if cfg.Cluster.Name != "west" {
+		t.Errorf("Cluster.Name = %q, want west", cfg.Cluster.Name)
+	}
+}
+
+func TestMultiClusterValidationErrorsArePrefixed(t *testing.T) {
+	content := multiClusterYAML + `  broken:
+    replicas:
+      - host: 10.3.0.2
+        replication_slot: "bad-slot"
+`
+	doc, err := Load(writeConfig(t, content))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	_, err = doc.All()
+	if err == nil {
+		t.Fatal("All() expected validation errors")
+	}
+	for _, want := range []string{"broken: primary.host is required", "broken: replicas[0].replication_slot: invalid replication slot name"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("All() error should contain %q, got: %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "east:") || strings.Contains(err.Error(), "west:") {
+		t.Errorf("valid clusters should not report errors, got: %v", err)
+	}
+}
+
+func TestMultiClusterRejectsStrayTopLevelKeys(t *testing.T) {
+	doc, err := Load(writeConfig(t, "primary:\n  host: 10.0.0.1\n"+multiClusterYAML))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if _, err := doc.Cluster("east"); err == nil || !strings.Contains(err.Error(), "top-level key 'primary'") {
+		t.Errorf("Cluster() error = %v, want stray key error", err)
+	}
+}
+
+const overlayBaseYAML = `cluster:
+  name: orders
+primary:
+  host: 10.0.0.1
+  db_name: postgres
+  db_user: postgres
+  db_password: password
+  replication_user: replicator
+  replication_password: password
+replicas:
+  - host: 10.0.0.2
+    replication_slot: slot_2
+    sync_mode: async
+  - host: 10.0.0.3
+    replication_slot: slot_3
+    sync_mode: async
+options:
+  wal_keep_size: 1GB
+  hot_standby: true
+monitoring:
+  datadog:
+    enabled: true
+    api_key: key
+    datadog_user_password: password
+`
+
+func TestOverlayMergeSemantics(t *testing.T) {
+	doc, err := Load(writeConfig(t, overlayBaseYAML))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	overlay := `primary:
+  host: 10.9.0.1
+replicas:
+  - host: 10.0.0.3
+    sync_mode: sync
+  - host: 10.0.0.4
+    replication_slot: slot_4
+    sync_mode: async
+options:
+  wal_keep_size: 4GB
+monitoring: null
+`
+	if err := doc.Overlay(writeConfig(t, overlay)); err != nil {
+		t.Fatalf("Overlay() failed: %v", err)
+	}
+
+	cfg, err := doc.Cluster("")
+	if err != nil {
+		t.Fatalf("Cluster() failed: %v", err)
+	}
+	if cfg.Primary.Host != "10.9.0.1" || cfg.Primary.DbPassword != "password" {
+		t.Errorf("primary not merged: %+v", cfg.Primary)
+	}
+	if cfg.Options.WalKeepSize != "4GB" || !cfg.Options.HotStandby {
+		t.Errorf("options not merged: %+v", cfg.Options)
+	}
+	if cfg.Monitoring != nil {
+		t.Errorf("explicit null should delete monitoring, got %+v", cfg.Monitoring)
+	}
+
+	if len(cfg.Replicas) != 3 {
+		t.Fatalf("len(Replicas) = %d, want 3", len(cfg.Replicas))
+	}
+	want := []struct{ host, slot, mode string }{
+		{"10.0.0.2", "slot_2", "async"},
+		{"10.0.0.3", "slot_3", "sync"},
+		{"10.0.0.4", "slot_4", "async"},
+	}
+	for i, w := range want {
+		r := cfg.Replicas[i]
+		if r.Host != w.host || r.ReplicationSlot != w.slot || r.SyncMode != w.mode {
+			t.Errorf("Replicas[%d] = %+v, want host=%s slot=%s sync_mode=%s", i, r, w.host, w.slot, w.mode)
+		}
+	}
+}
+
+func TestOverlaysApplyInOrder(t *testing.T) {
+	doc, err := Load(writeConfig(t, overlayBaseYAML))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	for _, overlay := range []string{"options:\n  wal_keep_size: 2GB\n", "options:\n  wal_keep_size: 8GB\n"} {
+		if err := doc.Overlay(writeConfig(t, overlay)); err != nil {
+			t.Fatalf("Overlay() failed: %v", err)
+		}
+	}
+	cfg, err := doc.Cluster("orders")
+	if err != nil {
+		t.Fatalf("Cluster() failed: %v", err)
+	}
+	if cfg.Options.WalKeepSize != "8GB" {
+		t.Errorf("wal_keep_size = %s, want the last overlay's 8GB", cfg.Options.WalKeepSize)
+	}
+}
+
+func TestOverlayValidationRunsAfterMerge(t *testing.T) {
+	doc, err := Load(writeConfig(t, overlayBaseYAML))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if err := doc.Overlay(writeConfig(t, "primary:\n  replication_password: null\n")); err != nil {
+		t.Fatalf("Overlay() failed: %v", err)
+	}
+	if _, err := doc.Cluster(""); err == nil || !strings.Contains(err.Error(), "primary.replication_password is required") {
+		t.Errorf("Cluster() error = %v, want missing replication_password", err)
+	}
+}
+
+func TestValidationErrorPositions(t *testing.T) {
+	base := writeConfig(t, `primary:
+  host: 10.0.0.1
+  db_name: postgres
+  db_user: postgres
+  db_password: password
+  replication_user: replicator
+  replication_password: password
+replicas:
+  - host: 10.0.0.2
+    replication_slot: slot_2
+    sync_mode: async
+  - host: 10.0.0.3
+    sync_mode: async
+options:
+  wal_level: bogus
+`)
+	overlayDir := t.TempDir()
+	overlay := filepath.Join(overlayDir, "prod.yaml")
+	if err := os.WriteFile(overlay, []byte("replicas:\n  - host: 10.0.0.2\n    sync_mode: maybe\n"), 0644); err != nil {
+		t.Fatalf("Failed to create overlay: %v", err)
+	}
+
+	doc, err := Load(base)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if err := doc.Overlay(overlay); err != nil {
+		t.Fatalf("Overlay() failed: %v", err)
+	}
+	_, err = doc.Cluster("")
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	want := map[string]struct {
+		file         string
+		line, column int
+	}{
+		// Missing fields point at their parent, here the replica item
+		"replicas[1].replication_slot": {base, 12, 5},
+		"options.wal_level":            {base, 15, 14},
+		// Values from an overlay point into the overlay file
+		"replicas[0].sync_mode": {overlay, 3, 16},
+	}
+	issues := ValidationErrors(err)
+	if len(issues) != len(want) {
+		t.Fatalf("got %d issues, want %d: %v", len(issues), len(want), err)
+	}
+	for _, issue := range issues {
+		w, ok := want[issue.Path]
+		if !ok {
+			t.Errorf("unexpected issue %v", issue)
+			continue
+		}
+		if issue.File != w.file || issue.Line != w.line || issue.Column != w.column {
+			t.Errorf("%s at %s:%d:%d, want %s:%d:%d", issue.Path, issue.File, issue.Line, issue.Column, w.file, w.line, w.column)
+		}
+		if issue.Severity != SeverityError {
+			t.Errorf("%s severity = %s, want error", issue.Path, issue.Severity)
+		}
+	}
+
+	wantMessage := fmt.Sprintf("%s:12:5: replicas[1].replication_slot is required", base)
+	if !strings.Contains(err.Error(), wantMessage) {
+		t.Errorf("error %q does not contain %q", err.Error(), wantMessage)
+	}
+}
+
+func TestValidationErrorsAcrossClusters(t *testing.T) {
+	doc, err := Load(writeConfig(t, `clusters:
+  orders:
+    primary:
+      host: 10.0.0.1
+  billing:
+    replicas: []
+`))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	_, err = doc.All()
+	clusters := map[string]bool{}
+	for _, issue := range ValidationErrors(err) {
+		clusters[issue.Cluster] = true
+		if !strings.HasPrefix(issue.Error(), fmt.Sprintf("%s:%d:%d: %s: ", issue.File, issue.Line, issue.Column, issue.Cluster)) {
+			t.Errorf("issue %q is not prefixed with its position and cluster", issue.Error())
+		}
+	}
+	if !clusters["orders"] || !clusters["billing"] {
+		t.Errorf("expected issues from both clusters, got %v", clusters)
+	}
+}
+
+func TestStrictModeFailsOnWarnings(t *testing.T) {
+	file := writeConfig(t, `primary:
+  host: 10.0.0.1
+  db_name: postgres
+  db_user: postgres
+  db_password: password
+  replication_user: replicator
+  replication_password: password
+replicas:
+  - host: 10.0.0.2
+    replication_slot: slot_2
+`)
+
+	doc, err := Load(file)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if _, err := doc.Cluster(""); err != nil {
+		t.Fatalf("Cluster() failed: %v", err)
+	}
+	warnings := doc.Warnings()
+	if len(warnings) != 1 || warnings[0].Line != 9 {
+		t.Fatalf("Warnings() = %v, want the defaulted sync_mode at line 9", warnings)
+	}
+
+	doc.Strict = true
+	_, err = doc.Cluster("")
+	if err == nil || !strings.Contains(err.Error(), "warning: replicas[0].sync_mode is not set") {
+		t.Errorf("strict Cluster() error = %v, want the sync_mode warning", err)
+	}
+	if len(doc.Warnings()) != 0 {
+		t.Errorf("strict mode should report warnings as errors only, got %v", doc.Warnings())
+	}
+}
@@ -0,0 +1,90 @@
+package config
+
+import "fmt"
+
+// Severity classifies a validation issue
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// ValidationError is a single validation issue. Path is the YAML path of the
+// offending field, e.g. "replicas[1].replication_slot". File, Line and Column
+// point at the field, or its closest parent when the field is missing, and are
+// only set when the config was loaded from a file.
+type ValidationError struct {
+	Cluster  string   `json:"cluster,omitempty"`
+	Path     string   `json:"path"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	message := e.Message
+	if e.Severity == SeverityWarning {
+		message = "warning: " + message
+	}
+	if e.Cluster != "" {
+		message = fmt.Sprintf("%s: %s", e.Cluster, message)
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, message)
+	}
+	return message
+}
+
+// fieldErrorf reports a problem with the field at path, e.g.
+// fieldErrorf("primary.host", "is required") gives "primary.host is required"
+func fieldErrorf(path, format string, args ...any) *ValidationError {
+	return &ValidationError{
+		Path:     path,
+		Severity: SeverityError,
+		Message:  path + " " + fmt.Sprintf(format, args...),
+	}
+}
+
+// fieldWarningf is fieldErrorf for issues that do not stop generation
+func fieldWarningf(path, format string, args ...any) *ValidationError {
+	warning := fieldErrorf(path, format, args...)
+	warning.Severity = SeverityWarning
+	return warning
+}
+
+// invalidField reports that the value of the field at path was rejected by err
+func invalidField(path string, err error) *ValidationError {
+	return &ValidationError{
+		Path:     path,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf("%s: %v", path, err),
+	}
+}
+
+// ValidationErrors returns every ValidationError wrapped in err, including
+// errors joined with errors.Join across clusters
+func ValidationErrors(err error) []*ValidationError {
+	var issues []*ValidationError
+	var walk func(error)
+	walk = func(err error) {
+		if issue, ok := err.(*ValidationError); ok {
+			issues = append(issues, issue)
+			return
+		}
+		switch wrapped := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, e := range wrapped.Unwrap() {
+				walk(e)
+			}
+		case interface{ Unwrap() error }:
+			walk(wrapped.Unwrap())
+		}
+	}
+	if err != nil {
+		walk(err)
+	}
+	return issues
+}
@@ -2,11 +2,27 @@ package config
 
 import (
 	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 func Print(cfg *Config) {
 	fmt.Printf("=== PostgreSQL HA Streaming Replication Configuration ===\n\n")
 
+	// Print cluster identity
+	if cfg.Cluster.Name != "" {
+		fmt.Printf("Cluster: %s\n", cfg.Cluster.Name)
+		if cfg.Cluster.Environment != "" {
+			fmt.Printf("  Environment: %s\n", cfg.Cluster.Environment)
+		}
+		if len(cfg.Cluster.Labels) > 0 {
+			fmt.Printf("  Labels: %d configured\n", len(cfg.Cluster.Labels))
+		}
+		fmt.Println()
+	}
+
 	// Print primary configuration
 	fmt.Printf("Primary Server:\n")
 	fmt.Printf("  Host: %s:%d\n", cfg.Primary.Host, cfg.Primary.Port)
@@ -23,6 +39,29 @@ func Print(cfg *Config) {
 			fmt.Printf("  %d. %s:%d\n", i+1, replica.Host, replica.Port)
 			fmt.Printf("     Replication Slot: %s\n", replica.ReplicationSlot)
 			fmt.Printf("     Sync Mode: %s\n", replica.SyncMode)
+			if replica.RecoveryMinApplyDelay != "" {
+				fmt.Printf("     Apply Delay: %s\n", replica.RecoveryMinApplyDelay)
+			}
+			if replica.Upstream != "" {
+				fmt.Printf("     Upstream: %s\n", replica.Upstream)
+			}
+		}
+	}
+
+	if len(cfg.LogicalSubscribers) > 0 {
+		fmt.Printf("\nLogical Subscribers (%d configured):\n", len(cfg.LogicalSubscribers))
+		for i, subscriber := range cfg.LogicalSubscribers {
+			fmt.Printf("  %d. %s (%s:%d/%s)\n", i+1, subscriber.Name, subscriber.Host, subscriber.Port, subscriber.DbName)
+			for _, publication := range subscriber.Publications {
+				fmt.Printf("     Publication %s:", publication.Name)
+				if len(publication.Tables) > 0 {
+					fmt.Printf(" tables %s", strings.Join(publication.Tables, ", "))
+				}
+				if len(publication.Schemas) > 0 {
+					fmt.Printf(" schemas %s", strings.Join(publication.Schemas, ", "))
+				}
+				fmt.Println()
+			}
 		}
 	}
 
@@ -30,11 +69,56 @@ func Print(cfg *Config) {
 	fmt.Printf("\nPostgreSQL Streaming Options:\n")
 	fmt.Printf("  WAL Level: %s\n", cfg.Options.WalLevel)
 	fmt.Printf("  Max WAL Senders: %d\n", cfg.Options.MaxWalSenders)
+	fmt.Printf("  Max Replication Slots: %d\n", cfg.Options.MaxReplicationSlots)
 	fmt.Printf("  WAL Keep Size: %s\n", cfg.Options.WalKeepSize)
 	fmt.Printf("  Hot Standby: %t\n", cfg.Options.HotStandby)
 	fmt.Printf("  Synchronous Commit: %s\n", cfg.Options.SynchronousCommit)
+	if names := cfg.SynchronousStandbyNames(); names != "" {
+		fmt.Printf("  Synchronous Standby Names: %s\n", names)
+	}
+	switch {
+	case !cfg.ArchiveEnabled():
+		fmt.Printf("  WAL Archiving: off\n")
+	case cfg.Options.Archive.Command != "":
+		fmt.Printf("  WAL Archiving: %s\n", cfg.Options.Archive.Command)
+	case !cfg.BackupEnabled():
+		fmt.Printf("  WAL Archiving: %s\n", cfg.Options.Archive.Directory)
+	}
 	fmt.Printf("  Auto-promote on Primary Failure: %t\n", cfg.Options.PromoteOnFailure)
 
+	if cfg.HAProxyEnabled() {
+		haproxy := cfg.Routing.HAProxy
+		fmt.Printf("\nHAProxy Routing:\n")
+		fmt.Printf("  Read-Write Port: %d\n", haproxy.ReadWritePort)
+		fmt.Printf("  Read-Only Port: %d\n", haproxy.ReadOnlyPort)
+		fmt.Printf("  Role Check Port: %d\n", haproxy.CheckPort)
+	}
+	if cfg.PgBouncerEnabled() {
+		pgbouncer := cfg.Pooling.PgBouncer
+		fmt.Printf("\nPgBouncer Pooling:\n")
+		fmt.Printf("  Listen Port: %d\n", pgbouncer.ListenPort)
+		fmt.Printf("  Pool Mode: %s\n", pgbouncer.PoolMode)
+		fmt.Printf("  Default Pool Size: %d\n", pgbouncer.DefaultPoolSize)
+		fmt.Printf("  Max Client Connections: %d\n", pgbouncer.MaxClientConn)
+	}
+	if cfg.BackupEnabled() {
+		backup := cfg.Backup
+		fmt.Printf("\nBackups:\n")
+		fmt.Printf("  Tool: %s\n", backup.Tool)
+		fmt.Printf("  Repository: %s %s\n", backup.Repository.Type, backup.Repository.Path)
+		fmt.Printf("  Full Backups: %s (keeping %d)\n", backup.FullSchedule, backup.RetentionFull)
+		if backup.DiffSchedule != "" {
+			fmt.Printf("  Differential Backups: %s\n", backup.DiffSchedule)
+		}
+	}
+	if cfg.VIPEnabled() {
+		vip := cfg.Routing.VIP
+		fmt.Printf("\nVirtual IP:\n")
+		fmt.Printf("  Address: %s\n", vip.Address)
+		fmt.Printf("  Interface: %s\n", vip.Interface)
+		fmt.Printf("  Virtual Router ID: %d\n", vip.RouterID)
+	}
+
 	fmt.Printf("\n=== Configuration Summary ===\n")
 	fmt.Printf("Total nodes: %d (1 primary + %d replicas)\n", 1+len(cfg.Replicas), len(cfg.Replicas))
 	fmt.Printf("Replication type: PostgreSQL Streaming Replication\n")
@@ -52,3 +136,13 @@ func maskPassword(password string) string {
 	}
 	return password[:2] + "****" + password[len(password)-2:]
 }
+
+// Render writes the fully merged and defaulted configuration as YAML
+func Render(w io.Writer, cfg *Config) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
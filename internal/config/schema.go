@@ -0,0 +1,225 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SchemaID is the identifier of the generated JSON schema
+const SchemaID = "https://github.com/HasithDeAlwis/ha-syncgen/cluster.schema.json"
+
+// schemaField describes the validation rules of a single setting. Fields are
+// keyed by their YAML path, with list items addressed through the list name,
+// e.g. "replicas.sync_mode".
+type schemaField struct {
+	Enum      []string
+	Default   any
+	Pattern   string
+	MaxLength int
+	MinItems  int
+	Required  bool
+}
+
+// schemaFields mirrors the rules applied by validation.go. Defaults must match
+// what ApplyDefaults fills in; the schema test enforces this.
+var schemaFields = map[string]schemaField{
+	"cluster.name":        {Pattern: clusterNamePattern.String(), MaxLength: maxClusterNameLength},
+	"cluster.environment": {Pattern: labelValuePattern.String()},
+
+	"primary":                      {Required: true},
+	"primary.host":                 {Required: true},
+	"primary.port":                 {Default: 5432},
+	"primary.data_directory":       {Default: "/var/lib/postgresql/data"},
+	"primary.db_name":              {Required: true},
+	"primary.db_user":              {Required: true},
+	"primary.db_password":          {Required: true},
+	"primary.replication_user":     {Required: true},
+	"primary.replication_password": {Required: true},
+
+	"replicas":                          {Required: true, MinItems: 1},
+	"replicas.host":                     {Required: true},
+	"replicas.port":                     {Default: 5432},
+	"replicas.replication_slot":         {Required: true, Pattern: replicationSlotPattern.String(), MaxLength: maxIdentifierLength},
+	"replicas.sync_mode":                {Enum: SyncModes, Default: "async"},
+	"replicas.recovery_min_apply_delay": {Pattern: applyDelayPattern.String()},
+
+	"options.wal_level":              {Enum: WalLevels, Default: "replica"},
+	"options.wal_keep_size":          {Default: "1GB", Pattern: `^(0|[0-9]+(kB|MB|GB|TB)?)$`},
+	"options.synchronous_commit":     {Enum: SynchronousCommitLevels, Default: "on"},
+	"options.sync_quorum.method":     {Enum: SyncQuorumMethods, Default: "first"},
+	"options.sync_quorum.num_sync":   {Default: 1},
+	"options.max_slot_wal_keep_size": {Pattern: `^(-1|0|[0-9]+(kB|MB|GB|TB)?)$`},
+	"options.slot_wal_warning_size":  {Default: "10GB", Pattern: `^(0|[0-9]+(kB|MB|GB|TB)?)$`},
+	"options.archive.enabled":        {Default: true},
+	"options.archive.directory":      {Pattern: backupPathPattern.String()},
+	"options.archive.timeout":        {Pattern: archiveTimeoutPattern.String()},
+
+	"logical_subscribers.name":                   {Required: true, Pattern: identifierPattern.String(), MaxLength: maxIdentifierLength},
+	"logical_subscribers.host":                   {Required: true},
+	"logical_subscribers.port":                   {Default: 5432},
+	"logical_subscribers.publications":           {Required: true, MinItems: 1},
+	"logical_subscribers.publications.name":      {Required: true, Pattern: identifierPattern.String(), MaxLength: maxIdentifierLength},
+	"logical_subscribers.publications.tables":    {Pattern: qualifiedNamePattern.String()},
+	"logical_subscribers.publications.schemas":   {Pattern: identifierPattern.String(), MaxLength: maxIdentifierLength},
+	"logical_subscribers.subscription.copy_data": {Default: true},
+
+	"monitoring.datadog.site":                  {Default: "datadoghq.com"},
+	"monitoring.datadog.agent_version":         {Default: "7.52.1", Pattern: agentVersionPattern.String()},
+	"monitoring.datadog.install_script_sha256": {Pattern: sha256Pattern.String()},
+
+	"routing.haproxy.bind":            {Default: "*"},
+	"routing.haproxy.read_write_port": {Default: 5000},
+	"routing.haproxy.read_only_port":  {Default: 5001},
+	"routing.haproxy.check_port":      {Default: 8008},
+	"routing.haproxy.check_interval":  {Default: "3s"},
+	"routing.vip.address":             {Required: true},
+	"routing.vip.interface":           {Required: true, Pattern: interfaceNamePattern.String()},
+	"routing.vip.router_id":           {Default: 51},
+
+	"pooling.pgbouncer.listen_port":       {Default: 6432},
+	"pooling.pgbouncer.pool_mode":         {Enum: PoolModes, Default: "transaction"},
+	"pooling.pgbouncer.default_pool_size": {Default: 20},
+	"pooling.pgbouncer.max_client_conn":   {Default: 100},
+	"pooling.pgbouncer.binary":            {Default: "/usr/sbin/pgbouncer", Pattern: binaryPathPattern.String()},
+
+	"backup.tool":            {Enum: BackupTools, Default: "none"},
+	"backup.stanza":          {Pattern: stanzaPattern.String()},
+	"backup.repository.type": {Enum: BackupRepositoryTypes, Default: "posix"},
+	"backup.repository.path": {Default: "/var/lib/pgbackup", Pattern: backupPathPattern.String()},
+	"backup.full_schedule":   {Default: "Sun *-*-* 01:00:00"},
+	"backup.retention_full":  {Default: 2},
+
+	"notifications.retries":         {Default: 3},
+	"notifications.retry_delay":     {Default: "5s"},
+	"notifications.sinks":           {Required: true, MinItems: 1},
+	"notifications.sinks.type":      {Required: true, Enum: NotificationSinkTypes},
+	"notifications.sinks.events":    {Enum: NotificationEvents},
+	"notifications.sinks.smtp.host": {Required: true},
+	"notifications.sinks.smtp.port": {Default: 587},
+	"notifications.sinks.smtp.from": {Required: true},
+	"notifications.sinks.smtp.to":   {Required: true, MinItems: 1},
+	"notifications.sinks.url":       {Pattern: `^https?://`},
+}
+
+// Schema returns a JSON schema (draft 2020-12) describing cluster config files,
+// both single-cluster files and files with `defaults:` and `clusters:`.
+func Schema() map[string]any {
+	configType := reflect.TypeOf(Config{})
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     SchemaID,
+		"title":   "ha-syncgen cluster configuration",
+		"oneOf": []any{
+			map[string]any{"$ref": "#/$defs/cluster"},
+			map[string]any{
+				"type":                 "object",
+				"required":             []string{"clusters"},
+				"additionalProperties": false,
+				"properties": map[string]any{
+					"defaults": map[string]any{"$ref": "#/$defs/clusterSettings"},
+					"clusters": map[string]any{
+						"type":                 "object",
+						"propertyNames":        map[string]any{"pattern": clusterNamePattern.String(), "maxLength": maxClusterNameLength},
+						"additionalProperties": map[string]any{"$ref": "#/$defs/clusterSettings"},
+					},
+				},
+			},
+		},
+		"$defs": map[string]any{
+			// A complete single-cluster file
+			"cluster": typeSchema(configType, "", true),
+			// Cluster settings that may be split between defaults and a cluster entry
+			"clusterSettings": typeSchema(configType, "", false),
+		},
+	}
+}
+
+// typeSchema builds the schema of a Go type from its yaml tags. List items share
+// the path of their list. Required fields are only enforced when required is set.
+func typeSchema(t reflect.Type, path string, required bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int:
+		return map[string]any{"type": "integer"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), path, required)}
+	case reflect.Map:
+		if path == "cluster.labels" {
+			return map[string]any{
+				"type":                 "object",
+				"propertyNames":        map[string]any{"pattern": labelKeyPattern.String()},
+				"additionalProperties": map[string]any{"type": "string", "pattern": labelValuePattern.String()},
+			}
+		}
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), path, required)}
+	case reflect.Struct:
+		properties := map[string]any{}
+		var requiredFields []string
+		for i := 0; i < t.NumField(); i++ {
+			name := yamlName(t.Field(i))
+			if name == "" {
+				continue
+			}
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			field := schemaFields[fieldPath]
+			properties[name] = annotate(typeSchema(t.Field(i).Type, fieldPath, required), field, required)
+			if field.Required {
+				requiredFields = append(requiredFields, name)
+			}
+		}
+		schema := map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+		if required && len(requiredFields) > 0 {
+			schema["required"] = requiredFields
+		}
+		return schema
+	default:
+		panic("config: no JSON schema mapping for " + t.String())
+	}
+}
+
+// annotate adds the rules of a field to its schema. Enums of lists apply to
+// their items.
+func annotate(schema map[string]any, field schemaField, required bool) map[string]any {
+	target := schema
+	if schema["type"] == "array" {
+		target = schema["items"].(map[string]any)
+		if field.MinItems > 0 && required {
+			schema["minItems"] = field.MinItems
+		}
+	}
+	if field.Enum != nil {
+		target["enum"] = field.Enum
+	}
+	if field.Pattern != "" {
+		target["pattern"] = field.Pattern
+	}
+	if field.MaxLength > 0 {
+		target["maxLength"] = field.MaxLength
+	}
+	if field.Default != nil {
+		schema["default"] = field.Default
+	}
+	return schema
+}
+
+// yamlName returns the key a struct field is decoded from, or "" if it is skipped
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "-" || !field.IsExported() {
+		return ""
+	}
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
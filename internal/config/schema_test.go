@@ -0,0 +1,241 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaTestYAML sets every required field and leaves every defaulted field unset
+const schemaTestYAML = `
+primary:
+  host: 10.0.0.1
+  db_name: postgres
+  db_user: postgres
+  db_password: password
+  replication_user: replicator
+  replication_password: password
+replicas:
+  - host: 10.0.0.2
+    replication_slot: replica_1
+monitoring:
+  datadog:
+    enabled: true
+    api_key: key
+    datadog_user_password: password
+routing:
+  haproxy:
+    enabled: true
+  vip:
+    address: 10.0.0.100
+    interface: eth0
+pooling:
+  pgbouncer:
+    enabled: true
+backup:
+  stanza: main
+notifications:
+  sinks:
+    - type: email
+      smtp:
+        host: smtp.example.com
+        from: ha@example.com
+        to: [ops@example.com]
+`
+
+// schemaTestLogicalYAML adds logical subscribers, which need wal_level logical,
+// so options.wal_level keeps its default in schemaTestYAML
+const schemaTestLogicalYAML = schemaTestYAML + `options:
+  wal_level: logical
+logical_subscribers:
+  - name: analytics
+    host: 10.0.2.10
+    publications:
+      - name: orders_pub
+        tables: [public.orders]
+        schemas: [billing]
+`
+
+// schemaTestYAMLFor returns the test config that covers a schema path
+func schemaTestYAMLFor(path string) string {
+	if strings.HasPrefix(path, "logical_subscribers") {
+		return schemaTestLogicalYAML
+	}
+	return schemaTestYAML
+}
+
+func sortedSchemaPaths() []string {
+	paths := make([]string, 0, len(schemaFields))
+	for path := range schemaFields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// fieldByPath follows a schema path through a value, using the first item of lists
+func fieldByPath(v reflect.Value, path string) (reflect.Value, bool) {
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Slice {
+			if v.Kind() == reflect.Pointer {
+				v = v.Elem()
+			} else if v.Len() > 0 {
+				v = v.Index(0)
+			} else {
+				return reflect.Value{}, false
+			}
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		found := false
+		for i := 0; i < v.NumField(); i++ {
+			if yamlName(v.Type().Field(i)) == name {
+				v = v.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return reflect.Value{}, false
+		}
+	}
+	return v, true
+}
+
+func TestSchemaFieldsExist(t *testing.T) {
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(schemaTestLogicalYAML), &cfg); err != nil {
+		t.Fatalf("failed to decode test config: %v", err)
+	}
+	for _, path := range sortedSchemaPaths() {
+		if _, ok := fieldByPath(reflect.ValueOf(&cfg), path); !ok {
+			t.Errorf("schemaFields[%q] does not match any config field", path)
+		}
+	}
+}
+
+func TestSchemaDefaultsMatchValidation(t *testing.T) {
+	configs := map[string]*Config{}
+	for _, data := range []string{schemaTestYAML, schemaTestLogicalYAML} {
+		var cfg Config
+		if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+			t.Fatalf("failed to decode test config: %v", err)
+		}
+		if err := Validate(&cfg); err != nil {
+			t.Fatalf("test config should be valid: %v", err)
+		}
+		configs[data] = &cfg
+	}
+
+	for _, path := range sortedSchemaPaths() {
+		want := schemaFields[path].Default
+		if want == nil {
+			continue
+		}
+		value, ok := fieldByPath(reflect.ValueOf(configs[schemaTestYAMLFor(path)]), path)
+		if !ok {
+			t.Errorf("%s: field not found", path)
+			continue
+		}
+		// Optional booleans are pointers so an explicit false can be told from unset
+		if value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+		if got := fmt.Sprint(value.Interface()); got != fmt.Sprint(want) {
+			t.Errorf("%s: validation defaults to %q, schema says %q", path, got, fmt.Sprint(want))
+		}
+	}
+}
+
+func TestSchemaRequiredFieldsMatchValidation(t *testing.T) {
+	for _, path := range sortedSchemaPaths() {
+		if !schemaFields[path].Required {
+			continue
+		}
+		t.Run(path, func(t *testing.T) {
+			var doc map[string]any
+			if err := yaml.Unmarshal([]byte(schemaTestYAMLFor(path)), &doc); err != nil {
+				t.Fatalf("failed to decode test config: %v", err)
+			}
+			deletePath(t, doc, strings.Split(path, "."))
+
+			data, err := yaml.Marshal(doc)
+			if err != nil {
+				t.Fatalf("failed to encode test config: %v", err)
+			}
+			var cfg Config
+			if err := yaml.Unmarshal(data, &cfg); err != nil {
+				t.Fatalf("failed to decode test config: %v", err)
+			}
+			if err := Validate(&cfg); err == nil {
+				t.Errorf("schema requires %s but validation accepts a config without it", path)
+			}
+		})
+	}
+}
+
+// deletePath removes the key at path from a decoded YAML document, descending
+// into the first item of lists
+func deletePath(t *testing.T, node any, path []string) {
+	t.Helper()
+	if list, ok := node.([]any); ok {
+		if len(list) == 0 {
+			t.Fatalf("cannot descend into empty list at %v", path)
+		}
+		node = list[0]
+	}
+	m, ok := node.(map[string]any)
+	if !ok {
+		t.Fatalf("expected a mapping at %v", path)
+	}
+	if len(path) == 1 {
+		delete(m, path[0])
+		return
+	}
+	deletePath(t, m[path[0]], path[1:])
+}
+
+func TestSchemaDocument(t *testing.T) {
+	data, err := json.Marshal(Schema())
+	if err != nil {
+		t.Fatalf("schema is not serialisable: %v", err)
+	}
+	var schema struct {
+		Defs map[string]struct {
+			Required   []string `json:"required"`
+			Properties map[string]struct {
+				Items struct {
+					Properties map[string]struct {
+						Enum    []string `json:"enum"`
+						Default any      `json:"default"`
+					} `json:"properties"`
+				} `json:"items"`
+			} `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("failed to decode schema: %v", err)
+	}
+
+	cluster := schema.Defs["cluster"]
+	for _, field := range []string{"primary", "replicas"} {
+		if !slices.Contains(cluster.Required, field) {
+			t.Errorf("cluster schema should require %s, got %v", field, cluster.Required)
+		}
+	}
+	if len(schema.Defs["clusterSettings"].Required) != 0 {
+		t.Errorf("clusterSettings must not require fields that may come from defaults")
+	}
+
+	syncMode := cluster.Properties["replicas"].Items.Properties["sync_mode"]
+	if !slices.Equal(syncMode.Enum, SyncModes) || syncMode.Default != "async" {
+		t.Errorf("replicas.sync_mode = %+v, want enum %v with default async", syncMode, SyncModes)
+	}
+}
@@ -3,18 +3,43 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// Validate applies defaults and checks the configuration. The returned error
+// wraps a *ValidationError for every error found; use ValidationErrors to
+// inspect them. Warnings are ignored; use Check to get them.
 func Validate(cfg *Config) error {
 	var errs []error
+	for _, issue := range Check(cfg) {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue)
+		}
+	}
+	return errors.Join(errs...)
+}
 
+// Check applies defaults and returns every error and warning for the configuration
+func Check(cfg *Config) []*ValidationError {
+	issues := ApplyDefaults(cfg)
+	issues = append(issues, validate(cfg)...)
+	issues = append(issues, validateTopology(cfg)...)
+	return append(issues, checkSemantics(cfg)...)
+}
+
+func validate(cfg *Config) []*ValidationError {
+	var errs []*ValidationError
+
+	errs = append(errs, validateCluster(&cfg.Cluster)...)
 	errs = append(errs, validatePrimaryConfig(&cfg.Primary)...)
 
 	if len(cfg.Replicas) == 0 {
-		errs = append(errs, fmt.Errorf("at least one replica is required"))
+		errs = append(errs, &ValidationError{Path: "replicas", Severity: SeverityError, Message: "at least one replica is required"})
 	}
 	replicationSlots := make(map[string]bool)
 	for i := range cfg.Replicas {
@@ -25,193 +50,862 @@ func Validate(cfg *Config) error {
 	errs = append(errs, validateOptions(&cfg.Options)...)
 
 	if cfg.Monitoring != nil {
-		errs = append(errs, validateMonitoringConfig(*cfg.Monitoring)...)
+		errs = append(errs, validateMonitoringConfig(cfg.Monitoring)...)
 	}
 
-	return errors.Join(errs...)
+	if cfg.Notifications != nil {
+		errs = append(errs, validateNotifications(cfg.Notifications)...)
+	}
+
+	errs = append(errs, validateLogicalSubscribers(cfg.LogicalSubscribers)...)
+
+	if cfg.HAProxyEnabled() {
+		errs = append(errs, validateHAProxy(cfg)...)
+	}
+
+	if cfg.VIPEnabled() {
+		errs = append(errs, validateVIP(cfg.Routing.VIP)...)
+	}
+
+	if cfg.PgBouncerEnabled() {
+		errs = append(errs, validatePgBouncer(cfg)...)
+	}
+
+	if cfg.Backup != nil {
+		errs = append(errs, validateBackup(cfg.Backup)...)
+		if cfg.BackupEnabled() && !cfg.ArchiveEnabled() {
+			errs = append(errs, fieldErrorf("options.archive.enabled", "is false but backup.tool '%s' needs WAL archiving", cfg.Backup.Tool))
+		}
+	}
+
+	return errs
 }
 
-func validateMonitoringConfig(monitoring Monitoring) []error {
-	var errs []error
+// checkSemantics warns about combinations of settings that are valid on their
+// own but unlikely to do what was intended
+func checkSemantics(cfg *Config) []*ValidationError {
+	var warnings []*ValidationError
+
+	if cfg.Options.SynchronousCommit == "off" {
+		for i, replica := range cfg.Replicas {
+			if replica.SyncMode == "sync" {
+				warnings = append(warnings, fieldWarningf(fmt.Sprintf("replicas[%d].sync_mode", i),
+					"is 'sync' but options.synchronous_commit is 'off', so commits will not wait for this replica"))
+			}
+		}
+	}
+	if cfg.Options.PromoteOnFailure && len(cfg.Replicas) > 0 {
+		promotable := false
+		for _, replica := range cfg.Replicas {
+			promotable = promotable || (!replica.Delayed() && replica.Upstream == "")
+		}
+		if !promotable {
+			warnings = append(warnings, fieldWarningf("options.promote_on_failure",
+				"is true but every replica is delayed or cascading, and those are never promoted automatically"))
+		}
+	}
+	// Slots are invalidated at max_slot_wal_keep_size, so warning at or above
+	// it means the first sign of trouble is a replica that must be re-seeded
+	if limit, ok := walSizeMB(cfg.Options.MaxSlotWalKeepSize); ok && limit > 0 {
+		if warning, ok := walSizeMB(cfg.Options.SlotWalWarningSize); ok && warning >= limit {
+			warnings = append(warnings, fieldWarningf("options.slot_wal_warning_size",
+				"is '%s', which is not below max_slot_wal_keep_size '%s', so slots are invalidated before the health check warns", cfg.Options.SlotWalWarningSize, cfg.Options.MaxSlotWalKeepSize))
+		}
+	}
+	if cfg.HAProxyEnabled() && !cfg.Options.HotStandby {
+		warnings = append(warnings, fieldWarningf("options.hot_standby",
+			"is false, so replicas reject the queries routing.haproxy sends to its read-only port"))
+	}
+	if cfg.Options.WalLevel == "minimal" && len(cfg.Replicas) > 0 {
+		warnings = append(warnings, fieldWarningf("options.wal_level",
+			"is 'minimal', which does not write enough WAL for streaming replication; use 'replica' or 'logical'"))
+	}
+	return warnings
+}
+
+// validateTopology checks how the nodes fit together: every node needs its own
+// address, and the primary needs enough WAL senders and slots for all replicas
+func validateTopology(cfg *Config) []*ValidationError {
+	var errs []*ValidationError
+
+	primaryAddress := net.JoinHostPort(cfg.Primary.Host, strconv.Itoa(cfg.Primary.Port))
+	hosts := make(map[string]int)
+	for i, replica := range cfg.Replicas {
+		if replica.Host == "" {
+			continue
+		}
+		path := fmt.Sprintf("replicas[%d].host", i)
+		// Generated files are laid out per replica host, so hosts must be unique
+		if first, ok := hosts[replica.Host]; ok {
+			errs = append(errs, fieldErrorf(path, "'%s' is already used by replicas[%d]", replica.Host, first))
+		} else {
+			hosts[replica.Host] = i
+		}
+		if net.JoinHostPort(replica.Host, strconv.Itoa(replica.Port)) == primaryAddress {
+			errs = append(errs, fieldErrorf(path, "'%s' with port %d is the primary's address", replica.Host, replica.Port))
+		}
+	}
+
+	for i, subscriber := range cfg.LogicalSubscribers {
+		if subscriber.Host == "" {
+			continue
+		}
+		path := fmt.Sprintf("logical_subscribers[%d].host", i)
+		if first, ok := hosts[subscriber.Host]; ok {
+			errs = append(errs, fieldErrorf(path, "'%s' is already used by replicas[%d]", subscriber.Host, first))
+		}
+		if net.JoinHostPort(subscriber.Host, strconv.Itoa(subscriber.Port)) == primaryAddress {
+			errs = append(errs, fieldErrorf(path, "'%s' with port %d is the primary's address", subscriber.Host, subscriber.Port))
+		}
+	}
+	if len(cfg.LogicalSubscribers) > 0 && cfg.Options.WalLevel != "logical" {
+		errs = append(errs, fieldErrorf("options.wal_level", "is '%s' but logical_subscribers require 'logical'", cfg.Options.WalLevel))
+	}
+
+	// The VIP is added to whichever node is primary, so it must not already
+	// belong to a node or clients could reach a replica through it
+	if cfg.VIPEnabled() {
+		if vip := parseVIPAddress(cfg.Routing.VIP.Address); vip != nil {
+			if sameAddress(vip, cfg.Primary.Host) {
+				errs = append(errs, fieldErrorf("routing.vip.address", "'%s' is the primary's address", cfg.Routing.VIP.Address))
+			}
+			for i, replica := range cfg.Replicas {
+				if sameAddress(vip, replica.Host) {
+					errs = append(errs, fieldErrorf("routing.vip.address", "'%s' is the address of replicas[%d]", cfg.Routing.VIP.Address, i))
+				}
+			}
+			for i, subscriber := range cfg.LogicalSubscribers {
+				if sameAddress(vip, subscriber.Host) {
+					errs = append(errs, fieldErrorf("routing.vip.address", "'%s' is the address of logical_subscribers[%d]", cfg.Routing.VIP.Address, i))
+				}
+			}
+		}
+	}
+
+	errs = append(errs, validateUpstreams(cfg, hosts)...)
+	for i, replica := range cfg.Replicas {
+		// synchronous_standby_names only applies to standbys of the primary
+		if replica.Upstream != "" && replica.SyncMode == "sync" {
+			errs = append(errs, fieldErrorf(fmt.Sprintf("replicas[%d].sync_mode", i), "cannot be 'sync' on a cascading replica (upstream '%s'); only replicas streaming from the primary can be synchronous", replica.Upstream))
+		}
+	}
+
+	// Each streaming replica holds one WAL sender and one slot on the server it
+	// streams from, and every node shares these settings, so they must cover the
+	// busiest server. Logical subscribers hold a sender and slot on the primary too.
+	// The headroom leaves room to re-seed a replica with pg_basebackup.
+	streams, source, clients := len(cfg.Downstreams(""))+len(cfg.LogicalSubscribers), "the primary", "replicas"
+	if len(cfg.LogicalSubscribers) > 0 {
+		clients = "replicas and logical subscribers"
+	}
+	for _, replica := range cfg.Replicas {
+		if n := len(cfg.Downstreams(replica.Host)); n > streams {
+			streams, source, clients = n, "replica "+replica.Host, "replicas"
+		}
+	}
+	needed := streams + replicationHeadroom
+	if cfg.Options.MaxWalSenders < streams {
+		errs = append(errs, fieldErrorf("options.max_wal_senders", "is %d but %d %s stream from %s; it must be at least %d", cfg.Options.MaxWalSenders, streams, clients, source, needed))
+	} else if cfg.Options.MaxWalSenders < needed {
+		errs = append(errs, fieldWarningf("options.max_wal_senders", "is %d, which leaves no headroom for %d %s streaming from %s; use at least %d so a replica can be re-seeded", cfg.Options.MaxWalSenders, streams, clients, source, needed))
+	}
+	if cfg.Options.MaxReplicationSlots < streams {
+		errs = append(errs, fieldErrorf("options.max_replication_slots", "is %d but %d %s each need a slot on %s; it must be at least %d", cfg.Options.MaxReplicationSlots, streams, clients, source, needed))
+	} else if cfg.Options.MaxReplicationSlots < needed {
+		errs = append(errs, fieldWarningf("options.max_replication_slots", "is %d, which leaves no headroom for %d %s streaming from %s; use at least %d", cfg.Options.MaxReplicationSlots, streams, clients, source, needed))
+	}
+
+	syncReplicas := len(cfg.SyncReplicas())
+	if cfg.Options.SynchronousCommit == "remote_apply" && syncReplicas == 0 {
+		errs = append(errs, fieldErrorf("options.synchronous_commit", "is 'remote_apply' but no replica has sync_mode 'sync'"))
+	}
+	// A quorum larger than the sync replicas can never be met, so every commit would hang
+	if syncReplicas > 0 && cfg.Options.SyncQuorum.NumSync > syncReplicas {
+		errs = append(errs, fieldErrorf("options.sync_quorum.num_sync", "is %d but only %d replicas have sync_mode 'sync'", cfg.Options.SyncQuorum.NumSync, syncReplicas))
+	}
+	return errs
+}
+
+// validateUpstreams checks that cascading replicas stream from another
+// configured replica and that following upstreams always ends at the primary.
+// hosts maps each replica host to its index.
+func validateUpstreams(cfg *Config, hosts map[string]int) []*ValidationError {
+	var errs []*ValidationError
+	for i, replica := range cfg.Replicas {
+		if replica.Upstream == "" {
+			continue
+		}
+		path := fmt.Sprintf("replicas[%d].upstream", i)
+		switch _, ok := hosts[replica.Upstream]; {
+		case replica.Upstream == replica.Host:
+			errs = append(errs, fieldErrorf(path, "'%s' is the replica itself", replica.Upstream))
+		case replica.Upstream == cfg.Primary.Host:
+			errs = append(errs, fieldErrorf(path, "'%s' is the primary; omit upstream to stream from the primary", replica.Upstream))
+		case !ok:
+			errs = append(errs, fieldErrorf(path, "'%s' is not the host of a configured replica", replica.Upstream))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	// Follow each chain of upstreams; reaching a replica twice means a cycle.
+	// Each cycle is reported once, at its first replica in the config.
+	reported := make(map[string]bool)
+	for i, replica := range cfg.Replicas {
+		chain := []string{replica.Host}
+		seen := map[string]bool{replica.Host: true}
+		for host := replica.Upstream; host != ""; host = cfg.Replicas[hosts[host]].Upstream {
+			chain = append(chain, host)
+			if host == replica.Host && !reported[host] {
+				for _, member := range chain {
+					reported[member] = true
+				}
+				errs = append(errs, fieldErrorf(fmt.Sprintf("replicas[%d].upstream", i), "forms a cycle: %s; one replica in it must stream from the primary", strings.Join(chain, " -> ")))
+			}
+			if seen[host] {
+				break
+			}
+			seen[host] = true
+		}
+	}
+	return errs
+}
+
+func validateCluster(cluster *Cluster) []*ValidationError {
+	var errs []*ValidationError
+
+	// The name is used in directory names, systemd unit names and application_name
+	if cluster.Name != "" {
+		if err := validateClusterName(cluster.Name); err != nil {
+			errs = append(errs, invalidField("cluster.name", err))
+		}
+	}
+	if cluster.Environment != "" && !labelValuePattern.MatchString(cluster.Environment) {
+		errs = append(errs, invalidField("cluster.environment", fmt.Errorf("invalid value '%s': may only contain letters, digits, '_', '.', '/' and '-'", cluster.Environment)))
+	}
+	for key, value := range cluster.Labels {
+		if !labelKeyPattern.MatchString(key) {
+			errs = append(errs, invalidField("cluster.labels", fmt.Errorf("invalid key '%s': must start with a letter and contain only lowercase letters, digits, '_', '.' and '-'", key)))
+		}
+		if !labelValuePattern.MatchString(value) {
+			errs = append(errs, invalidField("cluster.labels."+key, fmt.Errorf("invalid value '%s': may only contain letters, digits, '_', '.', '/' and '-'", value)))
+		}
+	}
+	return errs
+}
+
+func validateMonitoringConfig(monitoring *Monitoring) []*ValidationError {
+	var errs []*ValidationError
 
 	if monitoring.Datadog.Enabled {
-		errs = append(errs, validateDatadogConfig(monitoring.Datadog)...)
+		errs = append(errs, validateDatadogConfig(&monitoring.Datadog)...)
 	}
 
 	return errs
 }
 
-func validateDatadogConfig(datadog DatadogConfig) []error {
-	var errs []error
+func validateDatadogConfig(datadog *DatadogConfig) []*ValidationError {
+	var errs []*ValidationError
 	if datadog.ApiKey == "" {
-		errs = append(errs, fmt.Errorf("monitoring.datadog.api_key is required when Datadog is enabled"))
-	}
-	if datadog.Site == "" {
-		datadog.Site = "datadoghq.com" // Default site
+		errs = append(errs, fieldErrorf("monitoring.datadog.api_key", "is required when Datadog is enabled"))
 	}
 	if datadog.DatadogUserPassword == "" {
-		errs = append(errs, fmt.Errorf("monitoring.datadog.datadog_user_password is required when Datadog is enabled"))
+		errs = append(errs, fieldErrorf("monitoring.datadog.datadog_user_password", "is required when Datadog is enabled"))
+	}
+	if !agentVersionPattern.MatchString(datadog.AgentVersion) {
+		errs = append(errs, invalidField("monitoring.datadog.agent_version", fmt.Errorf("invalid version '%s': must be a full Agent 7 version such as 7.52.1", datadog.AgentVersion)))
+	}
+	if datadog.InstallScriptSHA256 != "" {
+		if !sha256Pattern.MatchString(datadog.InstallScriptSHA256) {
+			errs = append(errs, fieldErrorf("monitoring.datadog.install_script_sha256", "must be a lowercase hex sha256 digest"))
+		}
+	}
+	for i, tag := range datadog.Tags {
+		if tag == "" || strings.ContainsAny(tag, " ,") {
+			errs = append(errs, invalidField(fmt.Sprintf("monitoring.datadog.tags[%d]", i), fmt.Errorf("invalid tag '%s': must be non-empty and contain no spaces or commas", tag)))
+		}
 	}
 	return errs
 }
 
-func validateOptions(options *Options) []error {
-	var errs []error
+func validateHAProxy(cfg *Config) []*ValidationError {
+	var errs []*ValidationError
+	haproxy := &cfg.Routing.HAProxy
 
-	if options.WalLevel == "" {
-		options.WalLevel = "replica"
-	} else if err := validateWalLevel(options.WalLevel); err != nil {
-		errs = append(errs, fmt.Errorf("options.wal_level: %w", err))
+	if haproxy.Bind != "*" {
+		if err := validateHost(haproxy.Bind); err != nil {
+			errs = append(errs, invalidField("routing.haproxy.bind", err))
+		}
 	}
 
-	if options.MaxWalSenders <= 0 {
-		options.MaxWalSenders = 3 // Default
+	// HAProxy listens on these ports on its own host, so they must not collide
+	used := make(map[int]string)
+	for _, port := range []struct {
+		name  string
+		value int
+	}{
+		{"read_write_port", haproxy.ReadWritePort},
+		{"read_only_port", haproxy.ReadOnlyPort},
+		{"stats_port", haproxy.StatsPort},
+	} {
+		if port.name == "stats_port" && port.value == 0 {
+			continue
+		}
+		path := "routing.haproxy." + port.name
+		if port.value < 1 || port.value > 65535 {
+			errs = append(errs, fieldErrorf(path, "is %d but must be between 1 and 65535", port.value))
+		} else if other, ok := used[port.value]; ok {
+			errs = append(errs, fieldErrorf(path, "is %d, which is already used by routing.haproxy.%s", port.value, other))
+		}
+		used[port.value] = port.name
 	}
 
-	if options.WalKeepSize == "" {
-		options.WalKeepSize = "1GB"
-	} else if err := validateWalKeepSize(options.WalKeepSize); err != nil {
-		errs = append(errs, fmt.Errorf("options.wal_keep_size: %w", err))
+	// The role check listens on every node next to PostgreSQL
+	if haproxy.CheckPort < 1 || haproxy.CheckPort > 65535 {
+		errs = append(errs, fieldErrorf("routing.haproxy.check_port", "is %d but must be between 1 and 65535", haproxy.CheckPort))
+	} else if haproxy.CheckPort == cfg.Primary.Port {
+		errs = append(errs, fieldErrorf("routing.haproxy.check_port", "is %d, which is the primary's PostgreSQL port", haproxy.CheckPort))
+	} else {
+		for i, replica := range cfg.Replicas {
+			if haproxy.CheckPort == replica.Port {
+				errs = append(errs, fieldErrorf("routing.haproxy.check_port", "is %d, which is the PostgreSQL port of replicas[%d]", haproxy.CheckPort, i))
+				break
+			}
+		}
 	}
 
-	if options.SynchronousCommit == "" {
-		options.SynchronousCommit = "on"
-	} else if err := validateSynchronousCommit(options.SynchronousCommit); err != nil {
-		errs = append(errs, fmt.Errorf("options.synchronous_commit: %w", err))
+	if interval, err := time.ParseDuration(haproxy.CheckInterval); err != nil || interval <= 0 {
+		errs = append(errs, invalidField("routing.haproxy.check_interval", fmt.Errorf("invalid duration '%s'", haproxy.CheckInterval)))
 	}
 	return errs
 }
 
-func validateReplicaConfig(replica *Replica, replicationSlots map[string]bool, i int) []error {
-	var errs []error
+func validatePgBouncer(cfg *Config) []*ValidationError {
+	var errs []*ValidationError
+	pgbouncer := &cfg.Pooling.PgBouncer
 
-	if replica.Host == "" {
-		errs = append(errs, fmt.Errorf("replicas[%d].host is required", i))
+	if err := validatePoolMode(pgbouncer.PoolMode); err != nil {
+		errs = append(errs, invalidField("pooling.pgbouncer.pool_mode", err))
+	}
+	// PgBouncer runs next to PostgreSQL and the role check on every node
+	if pgbouncer.ListenPort < 1 || pgbouncer.ListenPort > 65535 {
+		errs = append(errs, fieldErrorf("pooling.pgbouncer.listen_port", "is %d but must be between 1 and 65535", pgbouncer.ListenPort))
+	} else if pgbouncer.ListenPort == cfg.Primary.Port {
+		errs = append(errs, fieldErrorf("pooling.pgbouncer.listen_port", "is %d, which is the primary's PostgreSQL port", pgbouncer.ListenPort))
+	} else if cfg.HAProxyEnabled() && pgbouncer.ListenPort == cfg.Routing.HAProxy.CheckPort {
+		errs = append(errs, fieldErrorf("pooling.pgbouncer.listen_port", "is %d, which is already used by routing.haproxy.check_port", pgbouncer.ListenPort))
+	} else {
+		for i, replica := range cfg.Replicas {
+			if pgbouncer.ListenPort == replica.Port {
+				errs = append(errs, fieldErrorf("pooling.pgbouncer.listen_port", "is %d, which is the PostgreSQL port of replicas[%d]", pgbouncer.ListenPort, i))
+				break
+			}
+		}
+	}
+	if pgbouncer.ReservePoolSize < 0 {
+		errs = append(errs, fieldErrorf("pooling.pgbouncer.reserve_pool_size", "is %d but must not be negative", pgbouncer.ReservePoolSize))
+	}
+	if !binaryPathPattern.MatchString(pgbouncer.Binary) {
+		errs = append(errs, invalidField("pooling.pgbouncer.binary", fmt.Errorf("invalid path '%s': must be absolute and contain only letters, digits, '_', '.', '-' and '/'", pgbouncer.Binary)))
+	}
+	return errs
+}
+
+func validateBackup(backup *Backup) []*ValidationError {
+	var errs []*ValidationError
+	if !slices.Contains(BackupTools, backup.Tool) {
+		errs = append(errs, invalidField("backup.tool", fmt.Errorf("invalid tool '%s': must be one of %v", backup.Tool, BackupTools)))
+	}
+	if !stanzaPattern.MatchString(backup.Stanza) {
+		errs = append(errs, invalidField("backup.stanza", fmt.Errorf("invalid stanza '%s': must contain only letters, digits, '_', '.' and '-'", backup.Stanza)))
+	}
+	if backup.RetentionFull < 1 {
+		errs = append(errs, fieldErrorf("backup.retention_full", "is %d but must be at least 1", backup.RetentionFull))
+	}
+	if backup.Tool == "local" && backup.DiffSchedule != "" {
+		errs = append(errs, fieldErrorf("backup.diff_schedule", "is set but local backups are always full; use pgbackrest or wal-g for differential backups"))
+	}
+
+	repository := &backup.Repository
+	switch repository.Type {
+	case "posix":
+		if !strings.HasPrefix(repository.Path, "/") || !backupPathPattern.MatchString(repository.Path) {
+			errs = append(errs, invalidField("backup.repository.path", fmt.Errorf("invalid path '%s': must be absolute and contain only letters, digits, '_', '.', '-' and '/'", repository.Path)))
+		}
+	case "s3":
+		if backup.Tool == "local" {
+			errs = append(errs, fieldErrorf("backup.repository.type", "is 's3' but local backups can only be stored in a posix directory"))
+		}
+		if !backupPathPattern.MatchString(repository.Path) {
+			errs = append(errs, invalidField("backup.repository.path", fmt.Errorf("invalid path '%s': must contain only letters, digits, '_', '.', '-' and '/'", repository.Path)))
+		}
+		if repository.Bucket == "" {
+			errs = append(errs, fieldErrorf("backup.repository.bucket", "is required for s3 repositories"))
+		}
+		if repository.Region == "" {
+			errs = append(errs, fieldErrorf("backup.repository.region", "is required for s3 repositories"))
+		}
+	default:
+		errs = append(errs, invalidField("backup.repository.type", fmt.Errorf("invalid type '%s': must be one of %v", repository.Type, BackupRepositoryTypes)))
+	}
+	return errs
+}
+
+func validateVIP(vip *VIPConfig) []*ValidationError {
+	var errs []*ValidationError
+	if vip.Address == "" {
+		errs = append(errs, fieldErrorf("routing.vip.address", "is required"))
+	} else if parseVIPAddress(vip.Address) == nil {
+		errs = append(errs, invalidField("routing.vip.address", fmt.Errorf("'%s' is not an IP address or CIDR", vip.Address)))
+	}
+	if vip.Interface == "" {
+		errs = append(errs, fieldErrorf("routing.vip.interface", "is required"))
+	} else if !interfaceNamePattern.MatchString(vip.Interface) {
+		errs = append(errs, invalidField("routing.vip.interface", fmt.Errorf("'%s' is not a network interface name", vip.Interface)))
 	}
-	if replica.Port <= 0 {
-		replica.Port = 5432 // Default PostgreSQL port
+	if vip.RouterID < 1 || vip.RouterID > 255 {
+		errs = append(errs, fieldErrorf("routing.vip.router_id", "is %d but must be between 1 and 255", vip.RouterID))
+	}
+	return errs
+}
+
+// parseVIPAddress returns the IP of a VIP address with an optional prefix
+// length, or nil if it is not one
+func parseVIPAddress(address string) net.IP {
+	if strings.Contains(address, "/") {
+		ip, _, err := net.ParseCIDR(address)
+		if err != nil {
+			return nil
+		}
+		return ip
+	}
+	return net.ParseIP(address)
+}
+
+// sameAddress reports whether host is the IP address ip. Hostnames are not
+// resolved, so they never match.
+func sameAddress(ip net.IP, host string) bool {
+	other := net.ParseIP(host)
+	return other != nil && other.Equal(ip)
+}
+
+func validateNotifications(notifications *Notifications) []*ValidationError {
+	var errs []*ValidationError
+
+	if notifications.Retries < 0 {
+		errs = append(errs, fieldErrorf("notifications.retries", "is %d but must not be negative", notifications.Retries))
+	}
+	if _, err := time.ParseDuration(notifications.RetryDelay); err != nil {
+		errs = append(errs, invalidField("notifications.retry_delay", fmt.Errorf("invalid duration '%s'", notifications.RetryDelay)))
+	}
+
+	if len(notifications.Sinks) == 0 {
+		errs = append(errs, fieldErrorf("notifications.sinks", "requires at least one sink"))
+	}
+	names := make(map[string]bool)
+	for i := range notifications.Sinks {
+		sink := &notifications.Sinks[i]
+		if names[sink.Name] {
+			errs = append(errs, fieldErrorf(fmt.Sprintf("notifications.sinks[%d].name", i), "'%s' is already used", sink.Name))
+		}
+		names[sink.Name] = true
+		errs = append(errs, validateNotificationSink(sink, i)...)
+	}
+	return errs
+}
+
+func validateLogicalSubscribers(subscribers []LogicalSubscriber) []*ValidationError {
+	var errs []*ValidationError
+
+	names := make(map[string]bool)
+	// Publications are created once on the primary, so subscribers may only
+	// share one if they define it the same way
+	publications := make(map[string]Publication)
+	publishedBy := make(map[string]int)
+	for i, subscriber := range subscribers {
+		path := fmt.Sprintf("logical_subscribers[%d]", i)
+		if subscriber.Name == "" {
+			errs = append(errs, fieldErrorf(path+".name", "is required"))
+		} else if err := validateIdentifier(subscriber.Name); err != nil {
+			errs = append(errs, invalidField(path+".name", err))
+		} else if names[subscriber.Name] {
+			errs = append(errs, fieldErrorf(path+".name", "'%s' is already used", subscriber.Name))
+		}
+		names[subscriber.Name] = true
+
+		if subscriber.Host == "" {
+			errs = append(errs, fieldErrorf(path+".host", "is required"))
+		} else if err := validateHost(subscriber.Host); err != nil {
+			errs = append(errs, invalidField(path+".host", err))
+		}
+
+		if len(subscriber.Publications) == 0 {
+			errs = append(errs, fieldErrorf(path+".publications", "requires at least one publication"))
+		}
+		for j, publication := range subscriber.Publications {
+			pubPath := fmt.Sprintf("%s.publications[%d]", path, j)
+			if publication.Name == "" {
+				errs = append(errs, fieldErrorf(pubPath+".name", "is required"))
+			} else if err := validateIdentifier(publication.Name); err != nil {
+				errs = append(errs, invalidField(pubPath+".name", err))
+			} else if previous, ok := publications[publication.Name]; ok && !samePublication(previous, publication) {
+				errs = append(errs, fieldErrorf(pubPath+".name", "'%s' is already defined by logical_subscribers[%d] with different tables or schemas", publication.Name, publishedBy[publication.Name]))
+			} else if !ok {
+				publications[publication.Name] = publication
+				publishedBy[publication.Name] = i
+			}
+
+			if len(publication.Tables) == 0 && len(publication.Schemas) == 0 {
+				errs = append(errs, fieldErrorf(pubPath, "must list tables or schemas"))
+			}
+			for k, table := range publication.Tables {
+				if !qualifiedNamePattern.MatchString(table) {
+					errs = append(errs, fieldErrorf(fmt.Sprintf("%s.tables[%d]", pubPath, k), "'%s' must be a table name, optionally qualified with its schema", table))
+				}
+			}
+			for k, schema := range publication.Schemas {
+				if err := validateIdentifier(schema); err != nil {
+					errs = append(errs, invalidField(fmt.Sprintf("%s.schemas[%d]", pubPath, k), err))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// samePublication reports whether two publications publish the same tables
+func samePublication(a, b Publication) bool {
+	return slices.Equal(a.Tables, b.Tables) && slices.Equal(a.Schemas, b.Schemas)
+}
+
+func validateNotificationSink(sink *NotificationSink, i int) []*ValidationError {
+	var errs []*ValidationError
+	path := fmt.Sprintf("notifications.sinks[%d]", i)
+
+	switch sink.Type {
+	case "webhook", "slack", "teams":
+		if sink.URL == "" {
+			errs = append(errs, fieldErrorf(path+".url", "is required for %s sinks", sink.Type))
+		} else if !strings.HasPrefix(sink.URL, "http://") && !strings.HasPrefix(sink.URL, "https://") {
+			errs = append(errs, fieldErrorf(path+".url", "'%s' must be an http or https URL", sink.URL))
+		}
+		if sink.Body != "" && sink.Type != "webhook" {
+			errs = append(errs, fieldErrorf(path+".body", "is only supported for webhook sinks"))
+		}
+	case "email":
+		if sink.SMTP == nil {
+			errs = append(errs, fieldErrorf(path+".smtp", "is required for email sinks"))
+			break
+		}
+		if sink.SMTP.Host == "" {
+			errs = append(errs, fieldErrorf(path+".smtp.host", "is required"))
+		}
+		if sink.SMTP.From == "" {
+			errs = append(errs, fieldErrorf(path+".smtp.from", "is required"))
+		}
+		if len(sink.SMTP.To) == 0 {
+			errs = append(errs, fieldErrorf(path+".smtp.to", "requires at least one recipient"))
+		}
+	default:
+		errs = append(errs, invalidField(path+".type", fmt.Errorf("invalid type '%s': must be one of %v", sink.Type, NotificationSinkTypes)))
+	}
+
+	for j, event := range sink.Events {
+		if err := validateNotificationEvent(event); err != nil {
+			errs = append(errs, invalidField(fmt.Sprintf("%s.events[%d]", path, j), err))
+		}
+	}
+	return errs
+}
+
+func validateOptions(options *Options) []*ValidationError {
+	var errs []*ValidationError
+
+	if err := validateWalLevel(options.WalLevel); err != nil {
+		errs = append(errs, invalidField("options.wal_level", err))
+	}
+
+	if err := validateWalKeepSize(options.WalKeepSize); err != nil {
+		errs = append(errs, invalidField("options.wal_keep_size", err))
+	}
+
+	if options.MaxSlotWalKeepSize != "" && options.MaxSlotWalKeepSize != "-1" {
+		if err := validateWalSize("max_slot_wal_keep_size", options.MaxSlotWalKeepSize); err != nil {
+			errs = append(errs, invalidField("options.max_slot_wal_keep_size", err))
+		}
+	}
+	if err := validateWalSize("slot_wal_warning_size", options.SlotWalWarningSize); err != nil {
+		errs = append(errs, invalidField("options.slot_wal_warning_size", err))
+	}
+
+	if err := validateSynchronousCommit(options.SynchronousCommit); err != nil {
+		errs = append(errs, invalidField("options.synchronous_commit", err))
+	}
+
+	if err := validateSyncQuorumMethod(options.SyncQuorum.Method); err != nil {
+		errs = append(errs, invalidField("options.sync_quorum.method", err))
+	}
+
+	archive := &options.Archive
+	if !strings.HasPrefix(archive.Directory, "/") || !backupPathPattern.MatchString(archive.Directory) {
+		errs = append(errs, invalidField("options.archive.directory", fmt.Errorf("invalid directory '%s': must be absolute and contain only letters, digits, '_', '.', '-' and '/'", archive.Directory)))
+	}
+	// The command is written inside single quotes in postgresql.conf
+	if archive.Command != "" {
+		if strings.Contains(archive.Command, "'") {
+			errs = append(errs, fieldErrorf("options.archive.command", "must not contain single quotes"))
+		}
+		if !strings.Contains(archive.Command, "%p") {
+			errs = append(errs, fieldErrorf("options.archive.command", "must contain %%p, the path of the WAL file to archive"))
+		}
+	}
+	if archive.Timeout != "" && !archiveTimeoutPattern.MatchString(archive.Timeout) {
+		errs = append(errs, invalidField("options.archive.timeout", fmt.Errorf("invalid archive_timeout '%s': use seconds, or a number with s, min or h", archive.Timeout)))
+	}
+	return errs
+}
+
+func validateReplicaConfig(replica *Replica, replicationSlots map[string]bool, i int) []*ValidationError {
+	var errs []*ValidationError
+	path := fmt.Sprintf("replicas[%d]", i)
+
+	if replica.Host == "" {
+		errs = append(errs, fieldErrorf(path+".host", "is required"))
+	} else if err := validateHost(replica.Host); err != nil {
+		errs = append(errs, invalidField(path+".host", err))
 	}
 	if replica.ReplicationSlot == "" {
-		errs = append(errs, fmt.Errorf("replicas[%d].replication_slot is required", i))
+		errs = append(errs, fieldErrorf(path+".replication_slot", "is required"))
 	} else {
 		// Check for duplicate replication slots
 		if replicationSlots[replica.ReplicationSlot] {
-			errs = append(errs, fmt.Errorf("replicas[%d].replication_slot '%s' is already used", i, replica.ReplicationSlot))
+			errs = append(errs, fieldErrorf(path+".replication_slot", "'%s' is already used", replica.ReplicationSlot))
 		}
 		replicationSlots[replica.ReplicationSlot] = true
 
 		// Validate replication slot name format
 		if err := validateReplicationSlotName(replica.ReplicationSlot); err != nil {
-			errs = append(errs, fmt.Errorf("replicas[%d].replication_slot: %w", i, err))
+			errs = append(errs, invalidField(path+".replication_slot", err))
 		}
 	}
 
-	if replica.SyncMode == "" {
-		fmt.Printf("Warning: replicas[%d].sync_mode is not set, defaulting to 'async'\n", i)
-		replica.SyncMode = "async"
-	} else if err := validateSyncMode(replica.SyncMode); err != nil {
-		errs = append(errs, fmt.Errorf("replicas[%d].sync_mode: %w", i, err))
+	if err := validateSyncMode(replica.SyncMode); err != nil {
+		errs = append(errs, invalidField(path+".sync_mode", err))
+	}
+	if replica.RecoveryMinApplyDelay != "" {
+		if !applyDelayPattern.MatchString(replica.RecoveryMinApplyDelay) {
+			errs = append(errs, invalidField(path+".recovery_min_apply_delay", fmt.Errorf("invalid delay '%s': must be a whole number with an optional unit (ms, s, min, h, d)", replica.RecoveryMinApplyDelay)))
+		} else if replica.Delayed() && replica.SyncMode == "sync" {
+			// A sync replica acknowledges commits long before applying them, so
+			// remote_apply would stall and failover would lose the delay window
+			errs = append(errs, fieldErrorf(path+".sync_mode", "cannot be 'sync' on a delayed replica (recovery_min_apply_delay '%s')", replica.RecoveryMinApplyDelay))
+		}
 	}
 	return errs
 }
 
-func validatePrimaryConfig(primary *Primary) []error {
-	var errs []error
+func validatePrimaryConfig(primary *Primary) []*ValidationError {
+	var errs []*ValidationError
 
 	if primary.Host == "" {
-		errs = append(errs, fmt.Errorf("primary.host is required"))
-	}
-
-	if primary.Port <= 0 {
-		primary.Port = 5432
-	}
-	if primary.DataDirectory == "" {
-		primary.DataDirectory = "/var/lib/postgresql/data" // Default
+		errs = append(errs, fieldErrorf("primary.host", "is required"))
+	} else if err := validateHost(primary.Host); err != nil {
+		errs = append(errs, invalidField("primary.host", err))
 	}
 	if primary.ReplicationUser == "" {
-		errs = append(errs, fmt.Errorf("primary.replication_user is required"))
+		errs = append(errs, fieldErrorf("primary.replication_user", "is required"))
 	}
 	if primary.ReplicationPassword == "" {
-		errs = append(errs, fmt.Errorf("primary.replication_password is required"))
+		errs = append(errs, fieldErrorf("primary.replication_password", "is required"))
 	}
 
 	if primary.DbName == "" {
-		errs = append(errs, fmt.Errorf("primary.db_name is required"))
+		errs = append(errs, fieldErrorf("primary.db_name", "is required"))
 	}
 	if primary.DbUser == "" {
-		errs = append(errs, fmt.Errorf("primary.db_user is required"))
+		errs = append(errs, fieldErrorf("primary.db_user", "is required"))
 	}
 	if primary.DbPassword == "" {
-		errs = append(errs, fmt.Errorf("primary.db_password is required"))
+		errs = append(errs, fieldErrorf("primary.db_password", "is required"))
 	}
 
 	return errs
 }
 
+// Allowed values for enumerated settings. The JSON schema is built from these lists.
+var (
+	SyncModes               = []string{"sync", "async"}
+	SyncQuorumMethods       = []string{"first", "any"}
+	WalLevels               = []string{"minimal", "replica", "logical"}
+	SynchronousCommitLevels = []string{"on", "off", "local", "remote_write", "remote_apply"}
+	NotificationSinkTypes   = []string{"webhook", "slack", "teams", "email"}
+	PoolModes               = []string{"session", "transaction", "statement"}
+	BackupTools             = []string{"none", "local", "pgbackrest", "wal-g"}
+	BackupRepositoryTypes   = []string{"posix", "s3"}
+)
+
+const (
+	maxClusterNameLength = 40
+	maxIdentifierLength  = 63 // PostgreSQL NAMEDATALEN - 1
+	replicationHeadroom  = 1  // spare WAL senders and slots beyond one per replica
+)
+
+var (
+	clusterNamePattern     = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	replicationSlotPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	labelKeyPattern        = regexp.MustCompile(`^[a-z][a-z0-9_.-]*$`)
+	labelValuePattern      = regexp.MustCompile(`^[A-Za-z0-9_./-]+$`)
+	agentVersionPattern    = regexp.MustCompile(`^7\.[0-9]+\.[0-9]+$`)
+	sha256Pattern          = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	applyDelayPattern      = regexp.MustCompile(`^([0-9]+)(ms|s|min|h|d)?$`) // PostgreSQL time; bare numbers are ms
+	identifierPattern      = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+	qualifiedNamePattern   = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\.[a-z_][a-z0-9_]*)?$`)
+	stanzaPattern          = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	backupPathPattern      = regexp.MustCompile(`^[A-Za-z0-9_./-]*$`)      // embedded in archive_command without quoting
+	binaryPathPattern      = regexp.MustCompile(`^/[A-Za-z0-9_./-]+$`)     // systemd ExecStart needs an absolute path
+	archiveTimeoutPattern  = regexp.MustCompile(`^[0-9]+(s|min|h)?$`)      // PostgreSQL time; bare numbers are seconds
+	interfaceNamePattern   = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,15}$`) // Linux IFNAMSIZ - 1
+)
+
+func validateClusterName(name string) error {
+	if !clusterNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid cluster name '%s': must contain only lowercase letters, digits and '-', and start and end with a letter or digit", name)
+	}
+	if len(name) > maxClusterNameLength {
+		return fmt.Errorf("cluster name '%s' too long: maximum 40 characters", name)
+	}
+	return nil
+}
+
+// validateHost accepts IPv4 and IPv6 addresses and DNS names. Hosts are rendered
+// into pg_hba.conf, so anything else would produce a broken or overly broad rule.
+// Labels are limited to ASCII letters, digits and hyphens (RFC 1123), so
+// internationalised names must be given in their punycode (xn--) form.
+func validateHost(host string) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if len(host) > 253 {
+		return fmt.Errorf("invalid host '%s': names are limited to 253 characters", host)
+	}
+	numeric := true
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("invalid host '%s': must be an IPv4 or IPv6 address or a DNS name", host)
+		}
+		for _, r := range label {
+			isDigit := r >= '0' && r <= '9'
+			if !isDigit && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && r != '-' {
+				return fmt.Errorf("invalid host '%s': must be an IPv4 or IPv6 address or a DNS name", host)
+			}
+			numeric = numeric && isDigit
+		}
+	}
+	if numeric {
+		return fmt.Errorf("invalid host '%s': not a valid IP address", host)
+	}
+	return nil
+}
+
+// validateIdentifier checks a name that is written unquoted into SQL
+func validateIdentifier(name string) error {
+	if !identifierPattern.MatchString(name) {
+		return fmt.Errorf("invalid name '%s': must be a lowercase SQL identifier", name)
+	}
+	if len(name) > maxIdentifierLength {
+		return fmt.Errorf("name '%s' too long: maximum 63 characters", name)
+	}
+	return nil
+}
+
 func validateReplicationSlotName(name string) error {
 	// PostgreSQL replication slot names must be valid SQL identifiers
-	matched, _ := regexp.MatchString(`^[a-zA-Z_][a-zA-Z0-9_]*$`, name)
-	if !matched {
+	if !replicationSlotPattern.MatchString(name) {
 		return fmt.Errorf("invalid replication slot name '%s': must be a valid SQL identifier", name)
 	}
-	if len(name) > 63 {
+	if len(name) > maxIdentifierLength {
 		return fmt.Errorf("replication slot name '%s' too long: maximum 63 characters", name)
 	}
 	return nil
 }
 
 func validateSyncMode(mode string) error {
-	validModes := []string{"sync", "async"}
-	for _, valid := range validModes {
+	for _, valid := range SyncModes {
+		if mode == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid sync_mode '%s': must be one of %v", mode, SyncModes)
+}
+
+func validateSyncQuorumMethod(method string) error {
+	for _, valid := range SyncQuorumMethods {
+		if method == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid method '%s': must be one of %v", method, SyncQuorumMethods)
+}
+
+func validatePoolMode(mode string) error {
+	for _, valid := range PoolModes {
 		if mode == valid {
 			return nil
 		}
 	}
-	return fmt.Errorf("invalid sync_mode '%s': must be one of %v", mode, validModes)
+	return fmt.Errorf("invalid pool mode '%s': must be one of %v", mode, PoolModes)
 }
 
 func validateWalLevel(level string) error {
-	validLevels := []string{"minimal", "replica", "logical"}
-	for _, valid := range validLevels {
+	for _, valid := range WalLevels {
 		if level == valid {
 			return nil
 		}
 	}
-	return fmt.Errorf("invalid wal_level '%s': must be one of %v", level, validLevels)
+	return fmt.Errorf("invalid wal_level '%s': must be one of %v", level, WalLevels)
 }
 
 func validateWalKeepSize(size string) error {
-	// Accept formats like "1GB", "512MB", "2048", etc.
-	if size == "0" {
-		return nil
+	return validateWalSize("wal_keep_size", size)
+}
+
+// validateWalSize checks a WAL size setting such as "1GB", "512MB" or "2048"
+func validateWalSize(setting, size string) error {
+	if _, ok := walSizeMB(size); !ok {
+		return fmt.Errorf("invalid %s '%s': must be a number with optional unit (kB, MB, GB, TB)", setting, size)
 	}
+	return nil
+}
 
-	// Check if it's just a number (in MB)
-	if _, err := strconv.Atoi(size); err == nil {
-		return nil
+// walSizeMB converts a WAL size setting to megabytes; bare numbers are
+// megabytes, as in postgresql.conf
+func walSizeMB(size string) (float64, bool) {
+	units := map[string]float64{"kB": 1.0 / 1024, "MB": 1, "GB": 1024, "TB": 1024 * 1024}
+	for unit, scale := range units {
+		if number, ok := strings.CutSuffix(size, unit); ok {
+			n, err := strconv.Atoi(number)
+			return float64(n) * scale, err == nil && n >= 0
+		}
 	}
+	n, err := strconv.Atoi(size)
+	return float64(n), err == nil && n >= 0
+}
 
-	// Check if it has valid unit suffix
-	validUnits := []string{"kB", "MB", "GB", "TB"}
-	for _, unit := range validUnits {
-		if strings.HasSuffix(size, unit) {
-			numPart := strings.TrimSuffix(size, unit)
-			if _, err := strconv.Atoi(numPart); err != nil {
-				return fmt.Errorf("invalid wal_keep_size '%s': numeric part must be an integer", size)
-			}
+func validateNotificationEvent(event string) error {
+	for _, valid := range NotificationEvents {
+		if event == valid {
 			return nil
 		}
 	}
-
-	return fmt.Errorf("invalid wal_keep_size '%s': must be a number with optional unit (kB, MB, GB, TB)", size)
+	return fmt.Errorf("invalid event '%s': must be one of %v", event, NotificationEvents)
 }
 
 func validateSynchronousCommit(commit string) error {
-	validValues := []string{"on", "off", "local", "remote_write", "remote_apply"}
-	for _, valid := range validValues {
+	for _, valid := range SynchronousCommitLevels {
 		if commit == valid {
 			return nil
 		}
 	}
-	return fmt.Errorf("invalid synchronous_commit '%s': must be one of %v", commit, validValues)
+	return fmt.Errorf("invalid synchronous_commit '%s': must be one of %v", commit, SynchronousCommitLevels)
 }
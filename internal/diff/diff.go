@@ -0,0 +1,157 @@
+// Package diff produces unified diffs of generated files
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change
+const context = 3
+
+type opKind int
+
+const (
+	equal opKind = iota
+	deleted
+	inserted
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns a unified diff turning a into b, or "" if they are equal.
+// oldName and newName label the --- and +++ header lines.
+func Unified(oldName, newName string, a, b []byte) string {
+	if string(a) == string(b) {
+		return ""
+	}
+	ops := lineOps(splitLines(string(a)), splitLines(string(b)))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// Find the next change and extend the hunk while changes are close together
+		first := start
+		for first < len(ops) && ops[first].kind == equal {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != equal {
+				last = i
+			} else if i-last > 2*context {
+				break
+			}
+		}
+		from := max(first-context, start)
+		to := min(last+context+1, len(ops))
+		writeHunk(&out, ops, from, to)
+		start = to
+	}
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, ops []op, from, to int) {
+	// Line numbers of the hunk start in each file
+	oldLine, newLine := 1, 1
+	for _, o := range ops[:from] {
+		if o.kind != inserted {
+			oldLine++
+		}
+		if o.kind != deleted {
+			newLine++
+		}
+	}
+	oldCount, newCount := 0, 0
+	var body strings.Builder
+	for _, o := range ops[from:to] {
+		switch o.kind {
+		case equal:
+			oldCount++
+			newCount++
+			body.WriteString(" ")
+		case deleted:
+			oldCount++
+			body.WriteString("-")
+		case inserted:
+			newCount++
+			body.WriteString("+")
+		}
+		body.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			body.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n%s", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount), body.String())
+}
+
+// hunkRange formats a hunk's start and length; an empty range starts at the line before it
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits s after each newline, keeping the newlines
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineOps computes the edit script from a to b using the longest common subsequence
+func lineOps(a, b []string) []op {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{equal, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{deleted, a[i]})
+			i++
+		default:
+			ops = append(ops, op{inserted, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{deleted, a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{inserted, b[j]})
+	}
+	return ops
+}
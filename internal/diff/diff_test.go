@@ -0,0 +1,67 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	lines := func(from, to int) string {
+		var b strings.Builder
+		for i := from; i <= to; i++ {
+			b.WriteString("line " + string(rune('a'+i-1)) + "\n")
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{
+			name: "equal",
+			old:  "same\n",
+			new:  "same\n",
+			want: "",
+		},
+		{
+			name: "new file",
+			old:  "",
+			new:  "one\ntwo\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name: "removed file",
+			old:  "one\n",
+			new:  "",
+			want: "--- a\n+++ b\n@@ -1 +0,0 @@\n-one\n",
+		},
+		{
+			name: "change with context",
+			old:  lines(1, 10),
+			new:  strings.Replace(lines(1, 10), "line e\n", "line E\n", 1),
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n line b\n line c\n line d\n-line e\n+line E\n line f\n line g\n line h\n",
+		},
+		{
+			name: "separate hunks",
+			old:  lines(1, 12),
+			new:  "line A\n" + lines(2, 11) + "line L\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-line a\n+line A\n line b\n line c\n line d\n" +
+				"@@ -9,4 +9,4 @@\n line i\n line j\n line k\n-line l\n+line L\n",
+		},
+		{
+			name: "missing trailing newline",
+			old:  "one\ntwo",
+			new:  "one\ntwo\n",
+			want: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n one\n-two\n\\ No newline at end of file\n+two\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("a", "b", []byte(tt.old), []byte(tt.new)); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"io"
+	"net"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of a single check
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Prober is how the doctor reaches the hosts in the config. The default
+// implementation dials TCP and runs commands over ssh; tests use fakes.
+type Prober interface {
+	// Dial checks that a TCP connection to address can be opened
+	Dial(ctx context.Context, address string) error
+	// Run executes a shell command on host and returns its standard output
+	Run(ctx context.Context, host, command string) (string, error)
+	// Now returns the local time, used to measure clock skew
+	Now() time.Time
+}
+
+// Result is the outcome of one check on one node
+type Result struct {
+	Node    string `json:"node"`
+	Check   string `json:"check"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Report collects the results of a doctor run in the order they were checked.
+// Cluster is the cluster.name of the config, empty for a single unnamed cluster.
+type Report struct {
+	Cluster string   `json:"cluster,omitempty"`
+	Results []Result `json:"results"`
+}
+
+// Failed reports whether any check failed
+func (r *Report) Failed() bool {
+	return r.count(Fail) > 0
+}
+
+func (r *Report) count(status Status) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes the report grouped by node, followed by a summary line
+func (r *Report) Print(w io.Writer) {
+	node := ""
+	for _, result := range r.Results {
+		if result.Node != node {
+			node = result.Node
+			fmt.Fprintf(w, "\n%s\n", node)
+		}
+		fmt.Fprintf(w, "  [%s] %-16s %s\n", strings.ToUpper(string(result.Status)), result.Check, result.Message)
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n", r.count(Pass), r.count(Warn), r.count(Fail))
+}
+
+// Tools the generated scripts call on each node
+var (
+	primaryTools = []string{"psql"}
+	replicaTools = []string{"pg_basebackup", "pg_rewind", "nc", "psql"}
+)
+
+const (
+	// minPostgresVersion is the first release with wal_keep_size
+	minPostgresVersion = 13
+	// schemaPublicationVersion is the first release with FOR TABLES IN SCHEMA
+	schemaPublicationVersion = 15
+	// Clock skew thresholds; lag and failover timestamps are compared across nodes
+	skewWarn = 2 * time.Second
+	skewFail = 30 * time.Second
+	// Replicas should have room for the primary's data plus this much growth
+	diskHeadroom = 1.5
+)
+
+// Commands run on the nodes over the Prober
+const (
+	sshCommand          = "true"
+	versionCommand      = "psql --version"
+	clockCommand        = "date +%s"
+	databaseSizeCommand = `sudo -n -u postgres psql -tAc "SELECT sum(pg_database_size(datname)) FROM pg_database"`
+)
+
+func dataDirectoryCommand(dir string) string {
+	return fmt.Sprintf("test -d '%s'", dir)
+}
+
+func toolsCommand(tools []string) string {
+	return fmt.Sprintf("for tool in %s; do command -v $tool >/dev/null || echo $tool; done", strings.Join(tools, " "))
+}
+
+func freeSpaceCommand(dir string) string {
+	return fmt.Sprintf("df -Pk '%s' | tail -1", dir)
+}
+
+var postgresVersionPattern = regexp.MustCompile(`\(PostgreSQL\) (\d+)`)
+
+// Doctor runs pre-flight checks for a cluster against the real hosts
+type Doctor struct {
+	config  *config.Config
+	prober  Prober
+	timeout time.Duration
+}
+
+// New creates a Doctor for a validated config. timeout bounds each probe.
+func New(cfg *config.Config, prober Prober, timeout time.Duration) *Doctor {
+	return &Doctor{config: cfg, prober: prober, timeout: timeout}
+}
+
+// node is a host from the config together with its role
+type node struct {
+	name    string
+	host    string
+	port    int
+	primary bool
+}
+
+// addFunc records the result of a check on the current node
+type addFunc func(check string, status Status, format string, args ...any)
+
+// Run checks every node in the config and returns the report
+func (d *Doctor) Run(ctx context.Context) *Report {
+	report := &Report{Cluster: d.config.Cluster.Name}
+	nodes := []node{{name: "primary " + d.config.Primary.Host, host: d.config.Primary.Host, port: d.config.Primary.Port, primary: true}}
+	for _, replica := range d.config.Replicas {
+		nodes = append(nodes, node{name: "replica " + replica.Host, host: replica.Host, port: replica.Port})
+	}
+
+	primaryVersion, primarySize := 0, int64(0)
+	for _, n := range nodes {
+		add := func(check string, status Status, format string, args ...any) {
+			report.Results = append(report.Results, Result{Node: n.name, Check: check, Status: status, Message: fmt.Sprintf(format, args...)})
+		}
+
+		d.checkPort(ctx, n, add)
+		if !d.checkSSH(ctx, n, add) {
+			continue
+		}
+		d.checkDataDirectory(ctx, n, add)
+		version := d.checkVersion(ctx, n, primaryVersion, add)
+		d.checkTools(ctx, n, add)
+		if n.primary {
+			primaryVersion = version
+			d.checkPublications(version, add)
+			primarySize = d.checkDatabaseSize(ctx, n, add)
+		} else {
+			d.checkDiskSpace(ctx, n, primarySize, add)
+		}
+		d.checkClock(ctx, n, add)
+	}
+	return report
+}
+
+func (d *Doctor) run(ctx context.Context, host, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	output, err := d.prober.Run(ctx, host, command)
+	return strings.TrimSpace(output), err
+}
+
+func (d *Doctor) checkPort(ctx context.Context, n node, add addFunc) {
+	address := net.JoinHostPort(n.host, strconv.Itoa(n.port))
+	dialCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	if err := d.prober.Dial(dialCtx, address); err != nil {
+		// Replicas may not be running PostgreSQL until setup_replication.sh has run
+		status := Warn
+		if n.primary {
+			status = Fail
+		}
+		add("postgres port", status, "cannot connect to %s: %v", address, err)
+		return
+	}
+	add("postgres port", Pass, "%s is reachable", address)
+}
+
+func (d *Doctor) checkSSH(ctx context.Context, n node, add addFunc) bool {
+	if _, err := d.run(ctx, n.host, sshCommand); err != nil {
+		add("ssh", Fail, "cannot run commands over ssh: %v (remaining checks skipped)", err)
+		return false
+	}
+	add("ssh", Pass, "ssh access works")
+	return true
+}
+
+func (d *Doctor) checkDataDirectory(ctx context.Context, n node, add addFunc) {
+	dir := d.config.Primary.DataDirectory
+	if _, err := d.run(ctx, n.host, dataDirectoryCommand(dir)); err != nil {
+		// setup_replication.sh creates the replica's data directory
+		if n.primary {
+			add("data directory", Fail, "%s does not exist", dir)
+		} else {
+			add("data directory", Pass, "%s will be created by setup_replication.sh", dir)
+		}
+		return
+	}
+	if n.primary {
+		add("data directory", Pass, "%s exists", dir)
+	} else {
+		add("data directory", Warn, "%s exists; setup_replication.sh will move it aside and take a fresh base backup", dir)
+	}
+}
+
+// checkVersion returns the PostgreSQL major version, or 0 if it is unknown
+func (d *Doctor) checkVersion(ctx context.Context, n node, primaryVersion int, add addFunc) int {
+	output, err := d.run(ctx, n.host, versionCommand)
+	match := postgresVersionPattern.FindStringSubmatch(output)
+	if err != nil || match == nil {
+		add("postgres version", Fail, "could not determine the PostgreSQL version: psql is not installed or not in PATH")
+		return 0
+	}
+	version, _ := strconv.Atoi(match[1])
+	switch {
+	case version < minPostgresVersion:
+		add("postgres version", Fail, "PostgreSQL %d is too old; %d or newer is required for wal_keep_size", version, minPostgresVersion)
+	case !n.primary && primaryVersion != 0 && version != primaryVersion:
+		add("postgres version", Fail, "PostgreSQL %d does not match the primary's %d; streaming replication needs the same major version", version, primaryVersion)
+	default:
+		add("postgres version", Pass, "PostgreSQL %d", version)
+	}
+	return version
+}
+
+// checkPublications fails when a publication lists schemas: and the primary
+// is too old to publish a whole schema
+func (d *Doctor) checkPublications(version int, add addFunc) {
+	var publications []string
+	for _, subscriber := range d.config.LogicalSubscribers {
+		for _, publication := range subscriber.Publications {
+			if len(publication.Schemas) > 0 && !slices.Contains(publications, publication.Name) {
+				publications = append(publications, publication.Name)
+			}
+		}
+	}
+	switch {
+	case len(publications) == 0 || version == 0:
+		return
+	case version < schemaPublicationVersion:
+		add("publications", Fail, "PostgreSQL %d cannot publish %s: schemas: needs FOR TABLES IN SCHEMA from PostgreSQL %d; list the tables instead",
+			version, strings.Join(publications, ", "), schemaPublicationVersion)
+	default:
+		add("publications", Pass, "PostgreSQL %d supports FOR TABLES IN SCHEMA", version)
+	}
+}
+
+func (d *Doctor) checkTools(ctx context.Context, n node, add addFunc) {
+	tools := replicaTools
+	if n.primary {
+		tools = primaryTools
+	}
+	output, err := d.run(ctx, n.host, toolsCommand(tools))
+	if err != nil {
+		add("tools", Fail, "could not check for %s: %v", strings.Join(tools, ", "), err)
+		return
+	}
+	if missing := strings.Fields(output); len(missing) > 0 {
+		add("tools", Fail, "not found in PATH: %s", strings.Join(missing, ", "))
+		return
+	}
+	add("tools", Pass, "%s installed", strings.Join(tools, ", "))
+}
+
+// checkDatabaseSize returns the total size of the primary's databases in bytes, or 0 if unknown
+func (d *Doctor) checkDatabaseSize(ctx context.Context, n node, add addFunc) int64 {
+	output, err := d.run(ctx, n.host, databaseSizeCommand)
+	size, parseErr := strconv.ParseInt(output, 10, 64)
+	if err != nil || parseErr != nil {
+		add("database size", Warn, "could not query database size (needs passwordless sudo to postgres); replica disk space will not be checked")
+		return 0
+	}
+	add("database size", Pass, "%s in total", formatBytes(size))
+	return size
+}
+
+func (d *Doctor) checkDiskSpace(ctx context.Context, n node, primarySize int64, add addFunc) {
+	if primarySize == 0 {
+		return
+	}
+	// The data directory may not exist yet, so check the filesystem it will be created on
+	dir := path.Dir(d.config.Primary.DataDirectory)
+	output, err := d.run(ctx, n.host, freeSpaceCommand(dir))
+	fields := strings.Fields(output)
+	if err != nil || len(fields) < 4 {
+		add("disk space", Warn, "could not determine free space on %s", dir)
+		return
+	}
+	availableKB, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		add("disk space", Warn, "could not parse free space on %s", dir)
+		return
+	}
+	available := availableKB * 1024
+	switch {
+	case available < primarySize:
+		add("disk space", Fail, "%s free on %s but the primary holds %s", formatBytes(available), dir, formatBytes(primarySize))
+	case float64(available) < float64(primarySize)*diskHeadroom:
+		add("disk space", Warn, "%s free on %s leaves little room beyond the primary's %s", formatBytes(available), dir, formatBytes(primarySize))
+	default:
+		add("disk space", Pass, "%s free on %s", formatBytes(available), dir)
+	}
+}
+
+func (d *Doctor) checkClock(ctx context.Context, n node, add addFunc) {
+	output, err := d.run(ctx, n.host, clockCommand)
+	seconds, parseErr := strconv.ParseInt(output, 10, 64)
+	if err != nil || parseErr != nil {
+		add("clock", Warn, "could not read the remote clock")
+		return
+	}
+	skew := time.Unix(seconds, 0).Sub(d.prober.Now().Truncate(time.Second))
+	if skew < 0 {
+		skew = -skew
+	}
+	switch {
+	case skew >= skewFail:
+		add("clock", Fail, "clock is off by %s; replication lag and failover timing will be wrong", skew)
+	case skew >= skewWarn:
+		add("clock", Warn, "clock is off by %s; check NTP", skew)
+	default:
+		add("clock", Pass, "clock skew under %s", skewWarn)
+	}
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
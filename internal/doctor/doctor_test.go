@@ -0,0 +1,240 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// fakeHost is the state of one node as seen by fakeProber
+type fakeHost struct {
+	portClosed bool
+	noSSH      bool
+	dataDir    bool
+	version    string
+	missing    string
+	dbSize     string
+	freeKB     string
+	clock      time.Time
+}
+
+type fakeProber struct {
+	hosts map[string]*fakeHost
+}
+
+func (p *fakeProber) Dial(ctx context.Context, address string) error {
+	host := address[:strings.LastIndex(address, ":")]
+	if p.hosts[host].portClosed {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (p *fakeProber) Run(ctx context.Context, host, command string) (string, error) {
+	h := p.hosts[host]
+	if h.noSSH {
+		return "", errors.New("Permission denied (publickey)")
+	}
+	switch {
+	case command == sshCommand:
+		return "", nil
+	case command == versionCommand:
+		return h.version, nil
+	case command == clockCommand:
+		return strconv.FormatInt(h.clock.Unix(), 10) + "\n", nil
+	case command == databaseSizeCommand:
+		return h.dbSize, nil
+	case strings.HasPrefix(command, "test -d"):
+		if !h.dataDir {
+			return "", errors.New("exit status 1")
+		}
+		return "", nil
+	case strings.HasPrefix(command, "for tool in"):
+		return h.missing, nil
+	case strings.HasPrefix(command, "df -Pk"):
+		return "/dev/sda1 104857600 52428800 " + h.freeKB + " 50% /var/lib/postgresql\n", nil
+	}
+	return "", errors.New("unexpected command: " + command)
+}
+
+func (p *fakeProber) Now() time.Time {
+	return now
+}
+
+func healthyHost() *fakeHost {
+	return &fakeHost{
+		dataDir: true,
+		version: "psql (PostgreSQL) 16.2",
+		dbSize:  "1073741824", // 1 GiB
+		freeKB:  "10485760",   // 10 GiB
+		clock:   now,
+	}
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Primary: config.Primary{Host: "10.0.0.1", Port: 5432, DataDirectory: "/var/lib/postgresql/data"},
+		Replicas: []config.Replica{
+			{Host: "10.0.0.2", Port: 5432},
+			{Host: "10.0.0.3", Port: 5432},
+		},
+	}
+}
+
+func findResult(t *testing.T, report *Report, node, check string) Result {
+	t.Helper()
+	for _, result := range report.Results {
+		if result.Node == node && result.Check == check {
+			return result
+		}
+	}
+	t.Fatalf("no %q result for %s in %+v", check, node, report.Results)
+	return Result{}
+}
+
+func TestDoctorHealthyCluster(t *testing.T) {
+	primary, replica1, replica2 := healthyHost(), healthyHost(), healthyHost()
+	replica1.dataDir, replica2.dataDir = false, false
+	prober := &fakeProber{hosts: map[string]*fakeHost{"10.0.0.1": primary, "10.0.0.2": replica1, "10.0.0.3": replica2}}
+
+	report := New(testConfig(), prober, time.Second).Run(context.Background())
+	for _, result := range report.Results {
+		if result.Status != Pass {
+			t.Errorf("%s %s = %s: %s", result.Node, result.Check, result.Status, result.Message)
+		}
+	}
+	if report.Failed() {
+		t.Error("Failed() = true for a healthy cluster")
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	if !strings.Contains(out.String(), "replica 10.0.0.3") || !strings.Contains(out.String(), "0 failed") {
+		t.Errorf("unexpected report output:\n%s", out.String())
+	}
+}
+
+func TestDoctorFindsProblems(t *testing.T) {
+	primary, replica1, replica2 := healthyHost(), healthyHost(), healthyHost()
+	replica1.version = "psql (PostgreSQL) 15.6"
+	replica1.missing = "pg_rewind\nnc\n"
+	replica1.freeKB = "524288" // 512 MiB
+	replica1.clock = now.Add(45 * time.Second)
+	replica2.noSSH = true
+	replica2.portClosed = true
+	prober := &fakeProber{hosts: map[string]*fakeHost{"10.0.0.1": primary, "10.0.0.2": replica1, "10.0.0.3": replica2}}
+
+	report := New(testConfig(), prober, time.Second).Run(context.Background())
+	if !report.Failed() {
+		t.Fatal("Failed() = false, expected failures")
+	}
+
+	tests := []struct {
+		node, check string
+		status      Status
+		contains    string
+	}{
+		{"replica 10.0.0.2", "data directory", Warn, "move it aside"},
+		{"replica 10.0.0.2", "postgres version", Fail, "does not match the primary's 16"},
+		{"replica 10.0.0.2", "tools", Fail, "pg_rewind, nc"},
+		{"replica 10.0.0.2", "disk space", Fail, "512.0 MiB free"},
+		{"replica 10.0.0.2", "clock", Fail, "45s"},
+		{"replica 10.0.0.3", "postgres port", Warn, "connection refused"},
+		{"replica 10.0.0.3", "ssh", Fail, "Permission denied"},
+	}
+	for _, tt := range tests {
+		result := findResult(t, report, tt.node, tt.check)
+		if result.Status != tt.status || !strings.Contains(result.Message, tt.contains) {
+			t.Errorf("%s %s = %s %q, want %s containing %q", tt.node, tt.check, result.Status, result.Message, tt.status, tt.contains)
+		}
+	}
+
+	// Checks after a failed ssh connection are skipped
+	for _, result := range report.Results {
+		if result.Node == "replica 10.0.0.3" && result.Check != "postgres port" && result.Check != "ssh" {
+			t.Errorf("unexpected %s check after ssh failed", result.Check)
+		}
+	}
+}
+
+func TestDoctorPrimaryFailures(t *testing.T) {
+	primary := healthyHost()
+	primary.portClosed = true
+	primary.dataDir = false
+	primary.version = "psql (PostgreSQL) 12.18"
+	primary.dbSize = ""
+	replica := healthyHost()
+	prober := &fakeProber{hosts: map[string]*fakeHost{"10.0.0.1": primary, "10.0.0.2": replica, "10.0.0.3": replica}}
+
+	report := New(testConfig(), prober, time.Second).Run(context.Background())
+	if result := findResult(t, report, "primary 10.0.0.1", "postgres port"); result.Status != Fail {
+		t.Errorf("closed primary port = %s, want fail", result.Status)
+	}
+	if result := findResult(t, report, "primary 10.0.0.1", "data directory"); result.Status != Fail {
+		t.Errorf("missing primary data directory = %s, want fail", result.Status)
+	}
+	if result := findResult(t, report, "primary 10.0.0.1", "postgres version"); result.Status != Fail || !strings.Contains(result.Message, "too old") {
+		t.Errorf("PostgreSQL 12 = %s %q, want fail as too old", result.Status, result.Message)
+	}
+	if result := findResult(t, report, "primary 10.0.0.1", "database size"); result.Status != Warn {
+		t.Errorf("unknown database size = %s, want warn", result.Status)
+	}
+}
+
+func TestDoctorSchemaPublications(t *testing.T) {
+	cfg := testConfig()
+	cfg.LogicalSubscribers = []config.LogicalSubscriber{{
+		Name:         "analytics",
+		Publications: []config.Publication{{Name: "billing_pub", Schemas: []string{"billing"}}, {Name: "orders_pub", Tables: []string{"public.orders"}}},
+	}}
+
+	tests := []struct {
+		version string
+		status  Status
+	}{
+		{"psql (PostgreSQL) 14.11", Fail},
+		{"psql (PostgreSQL) 15.6", Pass},
+	}
+	for _, tt := range tests {
+		primary, replica := healthyHost(), healthyHost()
+		primary.version, replica.version = tt.version, tt.version
+		prober := &fakeProber{hosts: map[string]*fakeHost{"10.0.0.1": primary, "10.0.0.2": replica, "10.0.0.3": replica}}
+
+		report := New(cfg, prober, time.Second).Run(context.Background())
+		result := findResult(t, report, "primary 10.0.0.1", "publications")
+		if result.Status != tt.status {
+			t.Errorf("%s: publications = %s %q, want %s", tt.version, result.Status, result.Message, tt.status)
+		}
+		if tt.status == Fail && (!strings.Contains(result.Message, "billing_pub") || strings.Contains(result.Message, "orders_pub")) {
+			t.Errorf("%s: message %q should name only billing_pub", tt.version, result.Message)
+		}
+	}
+}
+
+func TestDoctorReportCluster(t *testing.T) {
+	cfg := testConfig()
+	cfg.Cluster.Name = "orders"
+	primary, replica := healthyHost(), healthyHost()
+	prober := &fakeProber{hosts: map[string]*fakeHost{"10.0.0.1": primary, "10.0.0.2": replica, "10.0.0.3": replica}}
+
+	data, err := json.Marshal(New(cfg, prober, time.Second).Run(context.Background()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), `{"cluster":"orders","results":[`) {
+		t.Errorf("JSON report = %s, want it to start with the cluster name", data)
+	}
+
+	data, _ = json.Marshal(New(testConfig(), prober, time.Second).Run(context.Background()))
+	if strings.Contains(string(data), `"cluster"`) {
+		t.Errorf("JSON report for an unnamed cluster = %s, want no cluster field", data)
+	}
+}
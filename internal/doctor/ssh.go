@@ -0,0 +1,62 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSHProber reaches hosts over the network and runs commands with the system
+// ssh client, so ~/.ssh/config, agents and known_hosts apply as usual
+type SSHProber struct {
+	User         string
+	Port         int
+	IdentityFile string
+}
+
+func (p *SSHProber) Dial(ctx context.Context, address string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (p *SSHProber) Run(ctx context.Context, host, command string) (string, error) {
+	// BatchMode fails instead of prompting for passwords or host key confirmation
+	args := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=5"}
+	if p.Port > 0 {
+		args = append(args, "-p", strconv.Itoa(p.Port))
+	}
+	if p.IdentityFile != "" {
+		args = append(args, "-i", p.IdentityFile)
+	}
+	target := host
+	if p.User != "" {
+		target = p.User + "@" + host
+	}
+	args = append(args, target, command)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			message = strings.ReplaceAll(message, "\n", "; ")
+			return stdout.String(), fmt.Errorf("%w: %s", err, message)
+		}
+		return stdout.String(), err
+	}
+	return stdout.String(), nil
+}
+
+func (p *SSHProber) Now() time.Time {
+	return time.Now()
+}
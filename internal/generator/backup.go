@@ -0,0 +1,206 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Backup tool configuration files hold repository credentials
+const (
+	pgbackrestFile = "pgbackrest.conf"
+	walgFile       = "walg.json"
+)
+
+// backupSetup is what the setup scripts need to prepare a node for the backup
+// tool: its configuration installed where archive_command and restore_command
+// expect it, and the repository directories on the primary
+type backupSetup struct {
+	Tool           string
+	Stanza         string
+	ConfigFile     string
+	ConfigPath     string
+	Directories    []string
+	RestoreCommand string
+}
+
+// backupConfigPath returns where the backup tool's configuration is installed.
+// pgBackRest reads every file in conf.d; WAL-G is pointed at its file.
+func (g *Generator) backupConfigPath() string {
+	switch g.config.Backup.Tool {
+	case "pgbackrest":
+		return fmt.Sprintf("/etc/pgbackrest/conf.d/%s.conf", g.config.BackupConfigName())
+	case "wal-g":
+		return fmt.Sprintf("/etc/wal-g/%s.json", g.config.BackupConfigName())
+	}
+	return ""
+}
+
+// archiveCommand returns the archive_command: options.archive.command if set,
+// otherwise the backup tool's, otherwise a copy to options.archive.directory
+func (g *Generator) archiveCommand() string {
+	archive := g.config.Options.Archive
+	if archive.Command != "" {
+		return archive.Command
+	}
+	if !g.config.BackupEnabled() {
+		return fmt.Sprintf("test ! -f %s/%%f && cp %%p %s/%%f", archive.Directory, archive.Directory)
+	}
+	backup := g.config.Backup
+	switch backup.Tool {
+	case "pgbackrest":
+		return fmt.Sprintf("pgbackrest --stanza=%s archive-push %%p", backup.Stanza)
+	case "wal-g":
+		return fmt.Sprintf("wal-g --config %s wal-push %%p", g.backupConfigPath())
+	}
+	wal := path.Join(backup.Repository.Path, "wal")
+	return fmt.Sprintf("test ! -f %s/%%f && cp %%p %s/%%f", wal, wal)
+}
+
+// archiveDirectory returns the directory setup_primary.sh creates for archived
+// WAL, or "" when WAL is not archived to options.archive.directory
+func (g *Generator) archiveDirectory() string {
+	if !g.config.ArchiveEnabled() || g.config.BackupEnabled() || g.config.Options.Archive.Command != "" {
+		return ""
+	}
+	return g.config.Options.Archive.Directory
+}
+
+// restoreCommand returns the restore_command that fetches archived WAL from
+// the backup repository, or "" when no backup tool is configured
+func (g *Generator) restoreCommand() string {
+	if !g.config.BackupEnabled() {
+		return ""
+	}
+	backup := g.config.Backup
+	switch backup.Tool {
+	case "pgbackrest":
+		return fmt.Sprintf(`pgbackrest --stanza=%s archive-get %%f "%%p"`, backup.Stanza)
+	case "wal-g":
+		return fmt.Sprintf("wal-g --config %s wal-fetch %%f %%p", g.backupConfigPath())
+	}
+	return fmt.Sprintf("cp %s/%%f %%p", path.Join(backup.Repository.Path, "wal"))
+}
+
+// backupSetup returns the backup preparation for the setup scripts, or nil
+// when no backup tool is configured
+func (g *Generator) backupSetup() *backupSetup {
+	if !g.config.BackupEnabled() {
+		return nil
+	}
+	backup := g.config.Backup
+	setup := &backupSetup{
+		Tool:           backup.Tool,
+		Stanza:         backup.Stanza,
+		ConfigPath:     g.backupConfigPath(),
+		RestoreCommand: g.restoreCommand(),
+	}
+	switch backup.Tool {
+	case "pgbackrest":
+		setup.ConfigFile = pgbackrestFile
+	case "wal-g":
+		setup.ConfigFile = walgFile
+	}
+	if backup.Repository.Type == "posix" {
+		if backup.Tool == "local" {
+			setup.Directories = []string{path.Join(backup.Repository.Path, "wal"), path.Join(backup.Repository.Path, "base")}
+		} else {
+			setup.Directories = []string{backup.Repository.Path}
+		}
+	}
+	return setup
+}
+
+// walgSettings returns the WAL-G configuration for a node
+func (g *Generator) walgSettings(port int) map[string]string {
+	backup := g.config.Backup
+	repository := backup.Repository
+	settings := map[string]string{
+		"PGDATA": g.config.Primary.DataDirectory,
+		"PGHOST": "/var/run/postgresql",
+		"PGPORT": fmt.Sprint(port),
+	}
+	if repository.Type == "s3" {
+		settings["WALG_S3_PREFIX"] = "s3://" + path.Join(repository.Bucket, repository.Path)
+		settings["AWS_REGION"] = repository.Region
+		if repository.Endpoint != "" {
+			settings["AWS_ENDPOINT"] = repository.Endpoint
+			settings["AWS_S3_FORCE_PATH_STYLE"] = "true"
+		}
+		if repository.AccessKey != "" {
+			settings["AWS_ACCESS_KEY_ID"] = repository.AccessKey
+			settings["AWS_SECRET_ACCESS_KEY"] = repository.SecretKey
+		}
+	} else {
+		settings["WALG_FILE_PREFIX"] = repository.Path
+	}
+	// Differential backups are WAL-G delta backups on top of the last full one
+	if backup.DiffSchedule != "" {
+		settings["WALG_DELTA_MAX_STEPS"] = "6"
+	}
+	return settings
+}
+
+// generateBackupFiles creates a node's backup tool configuration, the backup
+// script and the systemd timers that run it. Every node gets them; the script
+// only backs up on the current primary.
+func (g *Generator) generateBackupFiles(nodeDir string, port int) error {
+	backup := g.config.Backup
+	unitName := g.config.BackupUnitName()
+	repoPath := backup.Repository.Path
+	if backup.Repository.Type == "s3" {
+		repoPath = "/" + strings.TrimPrefix(repoPath, "/") // pgBackRest S3 paths are absolute
+	}
+	data := map[string]interface{}{
+		"Node":         nodeDir,
+		"Port":         port,
+		"Cluster":      g.config.Cluster,
+		"Primary":      g.config.Primary,
+		"Backup":       backup,
+		"RepoPath":     repoPath,
+		"ConfigPath":   g.backupConfigPath(),
+		"NodeDir":      path.Join(g.config.InstallDirectory(), nodeDir),
+		"LogDirectory": g.config.LogDirectory(),
+		"UnitName":     unitName,
+	}
+
+	type backupFile struct{ template, filename string }
+	files := []backupFile{
+		{"backup.sh.tmpl", "backup.sh"},
+		{"ha-postgres-backup@.service.tmpl", unitName + "@.service"},
+	}
+	switch backup.Tool {
+	case "pgbackrest":
+		files = append(files, backupFile{"pgbackrest.conf.tmpl", pgbackrestFile})
+	case "wal-g":
+		data["Settings"] = g.walgSettings(port)
+		files = append(files, backupFile{"walg.json.tmpl", walgFile})
+	}
+	for _, file := range files {
+		tmpl, err := parseTemplateByName(file.template)
+		if err != nil {
+			return err
+		}
+		if err := g.renderFile(tmpl, data, filepath.Join(nodeDir, file.filename), file.filename); err != nil {
+			return err
+		}
+	}
+
+	timerTmpl, err := parseTemplateByName("ha-postgres-backup.timer.tmpl")
+	if err != nil {
+		return err
+	}
+	schedules := [][2]string{{"full", backup.FullSchedule}}
+	if backup.DiffSchedule != "" {
+		schedules = append(schedules, [2]string{"diff", backup.DiffSchedule})
+	}
+	for _, schedule := range schedules {
+		data["Type"], data["Schedule"] = schedule[0], schedule[1]
+		filename := fmt.Sprintf("%s-%s.timer", unitName, schedule[0])
+		if err := g.renderFile(timerTmpl, data, filepath.Join(nodeDir, filename), filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
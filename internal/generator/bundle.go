@@ -0,0 +1,106 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Bundle is everything one node needs, with an install.sh at its root that
+// copies the files to the install directory and runs the setup in order
+type Bundle struct {
+	// Node is the node's output directory name: primary or replica-<host>
+	Node string
+	// Files have paths relative to the bundle root
+	Files []File
+}
+
+// Bundles renders all files and groups them into one bundle per node
+func (g *Generator) Bundles() ([]Bundle, error) {
+	files, err := g.Render()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []string{"primary"}
+	for _, replica := range g.config.Replicas {
+		nodes = append(nodes, replicaDirName(replica.Host))
+	}
+
+	installTmpl, err := parseTemplateByName("install.sh.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	var bundles []Bundle
+	for _, node := range nodes {
+		bundle := Bundle{Node: node}
+		for _, file := range files {
+			if rel, ok := bundlePath(node, file.Path); ok {
+				file.Path = rel
+				bundle.Files = append(bundle.Files, file)
+			}
+		}
+
+		data := map[string]interface{}{
+			"Node":          node,
+			"ClusterName":   g.config.Cluster.Name,
+			"InstallDir":    path.Join(g.config.InstallDirectory(), node),
+			"Primary":       node == "primary",
+			"Pgpass":        node != "primary",
+			"Notify":        node != "primary" && g.config.Notifications != nil,
+			"UnitName":      g.config.HealthUnitName(),
+			"Datadog":       g.config.DatadogEnabled(),
+			"RoleCheck":     g.config.HAProxyEnabled(),
+			"RoleUnit":      g.config.RoleUnitName(),
+			"VIP":           g.config.VIPEnabled(),
+			"VIPInstance":   g.config.VIPInstanceName(),
+			"PgBouncer":     g.config.PgBouncerEnabled(),
+			"PgBouncerUnit": g.config.PgBouncerUnitName(),
+			"Backup":        g.config.BackupEnabled(),
+			"BackupUnit":    g.config.BackupUnitName(),
+			"BackupDiff":    g.config.BackupEnabled() && g.config.Backup.DiffSchedule != "",
+		}
+		if g.config.PgBouncerEnabled() {
+			data["PgBouncerBinary"] = g.config.Pooling.PgBouncer.Binary
+		}
+		var content bytes.Buffer
+		if err := installTmpl.Execute(&content, data); err != nil {
+			return nil, fmt.Errorf("failed to execute install.sh template for %s: %v", node, err)
+		}
+		bundle.Files = append([]File{{Path: "install.sh", Mode: 0755, Content: content.Bytes(), Template: installTmpl.Name()}}, bundle.Files...)
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
+
+// bundlePath returns where a generated file goes in a node's bundle. The
+// node's own directory becomes the bundle root; Datadog files keep their
+// layout so datadog-install.sh can find the node's check configuration.
+func bundlePath(node, filePath string) (string, bool) {
+	if rel, ok := strings.CutPrefix(filePath, node+"/"); ok {
+		return rel, true
+	}
+	switch {
+	case filePath == "datadog/datadog-install.sh":
+		return filePath, true
+	case filePath == "datadog/datadog.sql":
+		return filePath, node == "primary"
+	case strings.HasPrefix(filePath, "datadog/"+node+"/"):
+		return filePath, true
+	}
+	return "", false
+}
+
+// WriteBundle writes a bundle to sink under a top-level directory named after
+// the node, and closes the sink
+func WriteBundle(bundle Bundle, sink Sink) error {
+	for _, file := range bundle.Files {
+		file.Path = path.Join(bundle.Node, file.Path)
+		if err := sink.WriteFile(file); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Path, err)
+		}
+	}
+	return sink.Close()
+}
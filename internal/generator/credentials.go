@@ -0,0 +1,27 @@
+package generator
+
+import (
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"path/filepath"
+)
+
+// pgpassFile holds a replica's credentials for connecting to the primary
+const pgpassFile = "pgpass"
+
+// generatePgpass creates the password file the replica's scripts and
+// walreceiver use to authenticate as the replication user
+func (g *Generator) generatePgpass(replica config.Replica, replicaDir string) error {
+	pgpassTmpl, err := parseTemplateByName("pgpass.tmpl")
+	if err != nil {
+		return err
+	}
+	upstreamHost, upstreamPort := g.config.UpstreamAddress(replica)
+	data := map[string]interface{}{
+		"Replica":      replica,
+		"Primary":      g.config.Primary,
+		"UpstreamHost": upstreamHost,
+		"UpstreamPort": upstreamPort,
+	}
+	outputFile := filepath.Join(replicaDir, pgpassFile)
+	return g.renderFile(pgpassTmpl, data, outputFile, pgpassFile)
+}
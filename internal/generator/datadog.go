@@ -1,20 +1,43 @@
 package generator
 
 import (
+	"fmt"
 	"path/filepath"
+	"strings"
 	"text/template"
 )
 
+const (
+	// datadogInstallFile embeds the API key, so it is executable by its owner only
+	datadogInstallFile = "datadog-install.sh"
+	// datadogConfFile holds the monitoring user's password
+	datadogConfFile = "conf.yaml"
+)
+
+// datadogNode describes a node that gets its own Datadog postgres check configuration
+type datadogNode struct {
+	Dir  string
+	Role string
+	Host string
+	Port int
+	Tags []string
+}
+
 func (g *Generator) generateDatadogFiles(installTmpl, sqlTmpl, confTmpl *template.Template) error {
-	ddDir := filepath.Join(g.outputDir, "datadog")
+	ddDir := "datadog"
+	datadog := g.config.Monitoring.Datadog
+	majorVersion, minorVersion, _ := strings.Cut(datadog.AgentVersion, ".")
 	specs := []FileSpec{
 		{
 			Tmpl:     installTmpl,
 			Dir:      ddDir,
-			Filename: "datadog-install.sh",
+			Filename: datadogInstallFile,
 			Data: map[string]interface{}{
-				"DataDogApiKey": g.config.Monitoring.Datadog.ApiKey,
-				"DataDogSite":   g.config.Monitoring.Datadog.Site,
+				"DataDogApiKey":       datadog.ApiKey,
+				"DataDogSite":         datadog.Site,
+				"AgentMajorVersion":   majorVersion,
+				"AgentMinorVersion":   minorVersion,
+				"InstallScriptSHA256": datadog.InstallScriptSHA256,
 			},
 		},
 		{
@@ -22,22 +45,25 @@ func (g *Generator) generateDatadogFiles(installTmpl, sqlTmpl, confTmpl *templat
 			Dir:      ddDir,
 			Filename: "datadog.sql",
 			Data: map[string]interface{}{
-				"Password": g.config.Primary.DbPassword,
+				"Password": datadog.DatadogUserPassword,
 			},
 		},
-		{
+	}
+	for _, node := range g.datadogNodes() {
+		specs = append(specs, FileSpec{
 			Tmpl:     confTmpl,
-			Dir:      ddDir,
-			Filename: "datadog-conf.yaml",
+			Dir:      filepath.Join(ddDir, node.Dir),
+			Filename: datadogConfFile,
 			Data: map[string]interface{}{
-				"Host":          g.config.Primary.Host,
-				"Port":          g.config.Primary.Port,
+				"Role":          node.Role,
+				"Host":          node.Host,
+				"Port":          node.Port,
 				"DbName":        g.config.Primary.DbName,
-				"DbUser":        g.config.Primary.DbUser,
-				"DbPassword":    g.config.Primary.DbPassword,
-				"Datadirectory": g.config.Primary.DataDirectory,
+				"Password":      datadog.DatadogUserPassword,
+				"Tags":          node.Tags,
+				"DataDirectory": g.config.Primary.DataDirectory,
 			},
-		},
+		})
 	}
 	for _, spec := range specs {
 		if err := g.renderTemplate(spec.Tmpl, spec.Dir, spec.Filename, spec.Data); err != nil {
@@ -46,3 +72,32 @@ func (g *Generator) generateDatadogFiles(installTmpl, sqlTmpl, confTmpl *templat
 	}
 	return nil
 }
+
+// datadogNodes lists the primary and every replica with the tags applied to their checks
+func (g *Generator) datadogNodes() []datadogNode {
+	baseTags := append(g.config.ClusterTags(), g.config.Monitoring.Datadog.Tags...)
+	nodes := []datadogNode{
+		{
+			Dir:  "primary",
+			Role: "primary",
+			Host: g.config.Primary.Host,
+			Port: g.config.Primary.Port,
+			Tags: append([]string{"role:primary"}, baseTags...),
+		},
+	}
+	for _, replica := range g.config.Replicas {
+		tags := []string{
+			"role:replica",
+			fmt.Sprintf("replication_slot:%s", replica.ReplicationSlot),
+			fmt.Sprintf("sync_mode:%s", replica.SyncMode),
+		}
+		nodes = append(nodes, datadogNode{
+			Dir:  replicaDirName(replica.Host),
+			Role: "replica",
+			Host: replica.Host,
+			Port: replica.Port,
+			Tags: append(tags, baseTags...),
+		})
+	}
+	return nodes
+}
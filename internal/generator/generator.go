@@ -1,11 +1,11 @@
 package generator
 
 import (
+	"encoding/json"
 	"fmt"
-	"os"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
 	"path/filepath"
-	"runtime"
-	"syncgen/internal/config"
+	"strings"
 	"text/template"
 )
 
@@ -19,66 +19,122 @@ type FileSpec struct {
 
 // renderTemplate renders a template to a file in the given directory
 func (g *Generator) renderTemplate(tmpl *template.Template, dir, filename string, data map[string]interface{}) error {
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-	outputFile := filepath.Join(dir, filename)
-	return executeTemplateToFile(tmpl, data, outputFile, filename)
+	return g.renderFile(tmpl, data, filepath.Join(dir, filename), filename)
 }
 
 // Generator handles the generation of all configuration files
 type Generator struct {
-	config    *config.Config
-	outputDir string
+	config *config.Config
+	sink   Sink
+	files  []File
 }
 
-// New creates a new Generator instance
-func New(cfg *config.Config, outputDir string) *Generator {
+// New creates a new Generator that writes to sink. The sink may be nil when
+// only Render or Plan are used.
+func New(cfg *config.Config, sink Sink) *Generator {
 	return &Generator{
-		config:    cfg,
-		outputDir: outputDir,
+		config: cfg,
+		sink:   sink,
 	}
 }
 
-// GenerateAll generates all necessary files for the HA setup
+// GenerateAll generates all necessary files for the HA setup, writes them to
+// the sink and closes it
 func (g *Generator) GenerateAll() error {
-	// Generate primary configuration files
-	tmplDir, tmplErr := getTemplateDirectory()
-	if tmplErr != nil {
-		return fmt.Errorf("failed to get template directory: %w", tmplErr)
+	files, err := g.Render()
+	if err != nil {
+		return err
+	}
+	if recorder, ok := g.sink.(configRecorder); ok {
+		hash, err := g.configHash()
+		if err != nil {
+			return err
+		}
+		recorder.recordConfig(hash)
+	}
+	for _, file := range files {
+		if err := g.sink.WriteFile(file); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Path, err)
+		}
+	}
+	return g.sink.Close()
+}
+
+// Plan renders all files and compares them against an output directory
+// without writing anything
+func (g *Generator) Plan(dir string) (*Plan, error) {
+	files, err := g.Render()
+	if err != nil {
+		return nil, err
+	}
+	plan, err := PlanWrite(dir, files)
+	if err != nil {
+		return nil, err
+	}
+	plan.configHash, err = g.configHash()
+	if err != nil {
+		return nil, err
 	}
+	return plan, nil
+}
+
+// configHash identifies the config a build was generated from
+func (g *Generator) configHash() (string, error) {
+	data, err := json.Marshal(g.config)
+	if err != nil {
+		return "", err
+	}
+	return hashContent(data), nil
+}
+
+// Render generates all files for the HA setup in memory, with slash-separated
+// paths relative to the output root
+func (g *Generator) Render() ([]File, error) {
+	g.files = nil
+	if err := g.render(); err != nil {
+		return nil, err
+	}
+	return g.files, nil
+}
 
-	pgHbaTmpl, err := parseTemplateByName(tmplDir, "pg_hba.conf.tmpl")
+func (g *Generator) render() error {
+	// Generate primary configuration files
+	pgHbaTmpl, err := parseTemplateByName("pg_hba.conf.tmpl")
 	if err != nil {
 		return fmt.Errorf("failed to parse pg_hba.conf template: %w", err)
 	}
 
-	postgresqlConfTmpl, err := parseTemplateByName(tmplDir, "postgresql.tmpl")
+	postgresqlConfTmpl, err := parseTemplateByName("postgresql.tmpl")
 	if err != nil {
 		return fmt.Errorf("failed to parse postgresql.conf template: %w", err)
 	}
 
-	setupPrimaryTmpl, err := parseTemplateByName(tmplDir, "setup_primary.sh.tmpl")
+	setupPrimaryTmpl, err := parseTemplateByName("setup_primary.sh.tmpl")
 	if err != nil {
 		return fmt.Errorf("failed to parse setup_primary.sh template: %w", err)
 	}
 
-	if err := g.generatePrimaryFiles(pgHbaTmpl, postgresqlConfTmpl, setupPrimaryTmpl); err != nil {
+	reconcileSlotsTmpl, err := parseTemplateByName("reconcile_slots.sh.tmpl")
+	if err != nil {
+		return fmt.Errorf("failed to parse reconcile_slots.sh template: %w", err)
+	}
+
+	if err := g.generatePrimaryFiles(pgHbaTmpl, postgresqlConfTmpl, setupPrimaryTmpl, reconcileSlotsTmpl); err != nil {
 		return fmt.Errorf("failed to generate primary files: %w", err)
 	}
 
-	if g.config.Monitoring.Datadog.Enabled {
-		datadogInstallTmpl, err := parseTemplateByName(tmplDir, "datadog-install.sh.tmpl")
+	if g.config.DatadogEnabled() {
+		datadogInstallTmpl, err := parseTemplateByName("datadog-install.sh.tmpl")
 		if err != nil {
 			return fmt.Errorf("failed to parse datadog-install.sh template: %w", err)
 		}
 
-		datadogSQLTmpl, err := parseTemplateByName(tmplDir, "datadog.sql.tmpl")
+		datadogSQLTmpl, err := parseTemplateByName("datadog.sql.tmpl")
 		if err != nil {
 			return fmt.Errorf("failed to parse datadog.sql template: %w", err)
 		}
 
-		datadogConfTmpl, err := parseTemplateByName(tmplDir, "datadog-conf.yaml.tmpl")
+		datadogConfTmpl, err := parseTemplateByName("datadog-conf.yaml.tmpl")
 		if err != nil {
 			return fmt.Errorf("failed to parse datadog-conf.yaml template: %w", err)
 		}
@@ -88,6 +144,39 @@ func (g *Generator) GenerateAll() error {
 		}
 	}
 
+	if g.config.HAProxyEnabled() {
+		if err := g.generateHAProxyConfig(); err != nil {
+			return fmt.Errorf("failed to generate HAProxy files: %w", err)
+		}
+		if err := g.generateRoleCheck("primary", g.config.Primary.Host, g.config.Primary.Port); err != nil {
+			return fmt.Errorf("failed to generate HAProxy files: %w", err)
+		}
+	}
+
+	if g.config.BackupEnabled() {
+		if err := g.generateBackupFiles("primary", g.config.Primary.Port); err != nil {
+			return fmt.Errorf("failed to generate backup files: %w", err)
+		}
+	}
+
+	if g.config.PgBouncerEnabled() {
+		if err := g.generatePgBouncerFiles("primary"); err != nil {
+			return fmt.Errorf("failed to generate PgBouncer files: %w", err)
+		}
+	}
+
+	if g.config.VIPEnabled() {
+		if err := g.generateVIPFiles("primary", g.config.Primary.Host, g.config.Primary.Port, primaryVIPPriority); err != nil {
+			return fmt.Errorf("failed to generate Keepalived files: %w", err)
+		}
+	}
+
+	if len(g.config.LogicalSubscribers) > 0 {
+		if err := g.generateLogicalFiles(); err != nil {
+			return fmt.Errorf("failed to generate logical replication files: %w", err)
+		}
+	}
+
 	// Generate replica-specific files
 	for _, replica := range g.config.Replicas {
 		if err := g.generateReplicaFiles(replica); err != nil {
@@ -100,21 +189,37 @@ func (g *Generator) GenerateAll() error {
 
 // generateReplicaFiles generates all files specific to a replica
 func (g *Generator) generateReplicaFiles(replica config.Replica) error {
-	replicaDir := filepath.Join(g.outputDir, fmt.Sprintf("replica-%s", replica.Host))
-	if err := os.MkdirAll(replicaDir, 0755); err != nil {
-		return err
-	}
+	replicaDir := replicaDirName(replica.Host)
 
 	// Generate sync script
 	if err := g.generateSyncScript(replica, replicaDir); err != nil {
 		return err
 	}
 
+	// Replicas that others stream from need their own pg_hba.conf entries
+	if len(g.config.Downstreams(replica.Host)) > 0 {
+		if err := g.generateUpstreamHba(replica, replicaDir); err != nil {
+			return err
+		}
+	}
+
+	// Generate replication credentials
+	if err := g.generatePgpass(replica, replicaDir); err != nil {
+		return err
+	}
+
 	// Generate health check script
 	if err := g.generateHealthCheckScript(replica, replicaDir); err != nil {
 		return err
 	}
 
+	// Generate notification helpers sourced by the health check
+	if g.config.Notifications != nil {
+		if err := g.generateNotifyScript(replica, replicaDir); err != nil {
+			return err
+		}
+	}
+
 	// Generate systemd service
 	if err := g.generateSystemdService(replica, replicaDir); err != nil {
 		return err
@@ -125,23 +230,39 @@ func (g *Generator) generateReplicaFiles(replica config.Replica) error {
 		return err
 	}
 
-	return nil
-}
+	// Generate the role check HAProxy routes by
+	if g.config.HAProxyEnabled() {
+		if err := g.generateRoleCheck(replicaDir, replica.Host, replica.Port); err != nil {
+			return err
+		}
+	}
+
+	// Generate the backup tool configuration and timers
+	if g.config.BackupEnabled() {
+		if err := g.generateBackupFiles(replicaDir, replica.Port); err != nil {
+			return err
+		}
+	}
 
-func getTemplateDirectory() (string, error) {
-	_, source, _, ok := runtime.Caller(0)
-	if !ok {
-		return "", fmt.Errorf("unable to get caller information")
+	// Generate PgBouncer and the timer that repoints it at the current primary
+	if g.config.PgBouncerEnabled() {
+		if err := g.generatePgBouncerFiles(replicaDir); err != nil {
+			return err
+		}
 	}
-	absPath, err := filepath.Abs(source)
-	if err != nil {
-		return "", err
+
+	// Generate the Keepalived configuration that moves the VIP after a failover
+	if g.config.VIPEnabled() {
+		if err := g.generateVIPFiles(replicaDir, replica.Host, replica.Port, replicaVIPPriority); err != nil {
+			return err
+		}
 	}
-	generatorDir := filepath.Dir(absPath)
-	return filepath.Join(generatorDir, "templates"), nil
+
+	return nil
 }
 
-func getTemplate(templateDirectory string, templateName string) (string, error) {
-	templatePath := filepath.Join(templateDirectory, templateName)
-	return templatePath, nil
+// replicaDirName returns the output directory name used for a replica's files.
+// Colons in IPv6 addresses are replaced so the path can be used with scp.
+func replicaDirName(host string) string {
+	return fmt.Sprintf("replica-%s", strings.ReplaceAll(host, ":", "-"))
 }
@@ -0,0 +1,662 @@
+package generator
+
+import (
+	"encoding/json"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNotifyScriptFiles(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Notifications = &config.Notifications{
+		Retries:    3,
+		RetryDelay: "5s",
+		Sinks: []config.NotificationSink{{
+			Name: "mail",
+			Type: "smtp",
+			SMTP: &config.SMTPConfig{Host: "smtp.example.com", Port: 587, Username: "alerts", Password: "hunter2", From: "ha@example.com", To: []string{"ops@example.com"}},
+		}},
+	}
+
+	g := New(cfg, nil)
+	files, err := g.Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	byPath := map[string]File{}
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+	if notify, ok := byPath["replica-10.0.0.2/notify.sh"]; !ok || notify.Mode != 0700 {
+		t.Errorf("notify.sh mode = %v, want 0700", notify.Mode)
+	}
+
+	bundles, err := g.Bundles()
+	if err != nil {
+		t.Fatalf("Bundles() error = %v", err)
+	}
+	install := string(bundles[1].Files[0].Content)
+	if !strings.Contains(install, `chown postgres:postgres "$INSTALL_DIR/notify.sh"`) {
+		t.Errorf("install.sh should hand notify.sh to postgres:\n%s", install)
+	}
+}
+
+func TestNotifyJSONEscape(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	cfg := testConfig(t)
+	cfg.Notifications = &config.Notifications{
+		Retries:    3,
+		RetryDelay: "5s",
+		Sinks:      []config.NotificationSink{{Name: "hook", Type: "webhook", URL: "https://hooks.example.com/x", Body: config.DefaultWebhookBody}},
+	}
+	files, err := New(cfg, nil).Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	var script string
+	for _, file := range files {
+		if file.Path == "replica-10.0.0.2/notify.sh" {
+			script = string(file.Content)
+		}
+	}
+	start := strings.Index(script, "json_escape() {")
+	end := strings.Index(script[start:], "\n}\n")
+	if start < 0 || end < 0 {
+		t.Fatal("notify.sh has no json_escape function")
+	}
+	function := script[start : start+end+3]
+
+	value := "a\"b\\c\nd\te\rf\x01g\x1bh é"
+	paths := map[string]string{"without jq": t.TempDir()}
+	if _, err := exec.LookPath("jq"); err == nil {
+		paths["with jq"] = os.Getenv("PATH")
+	}
+	for name, path := range paths {
+		cmd := exec.Command(bash, "-c", function+`printf '"%s"' "$(json_escape "$1")"`, "bash", value)
+		cmd.Env = []string{"PATH=" + path, "LC_ALL=C"}
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%s: json_escape failed: %v", name, err)
+		}
+		var decoded string
+		if err := json.Unmarshal(output, &decoded); err != nil || decoded != value {
+			t.Errorf("%s: json_escape produced %s, which decodes to %q (%v), want %q", name, output, decoded, err, value)
+		}
+	}
+}
+
+func TestDatadogFileModes(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Monitoring = &config.Monitoring{Datadog: config.DatadogConfig{
+		Enabled: true, ApiKey: "dd-key", Site: "datadoghq.com", DatadogUserPassword: "dd-secret", AgentVersion: "7.52",
+	}}
+
+	files, err := New(cfg, nil).Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	modes := map[string]os.FileMode{}
+	for _, file := range files {
+		modes[file.Path] = file.Mode
+	}
+	for path, want := range map[string]os.FileMode{
+		"datadog/datadog-install.sh":         0700,
+		"datadog/primary/conf.yaml":          0600,
+		"datadog/replica-10.0.0.2/conf.yaml": 0600,
+	} {
+		if got, ok := modes[path]; !ok || got != want {
+			t.Errorf("%s mode = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestCascadingReplicaFiles(t *testing.T) {
+	cfg := testConfig(t)
+	downstream := cfg.Replicas[0]
+	downstream.Host = "10.0.0.3"
+	downstream.ReplicationSlot = "replica_2"
+	downstream.Upstream = "10.0.0.2"
+	cfg.Replicas = append(cfg.Replicas, downstream)
+
+	files, err := New(cfg, nil).Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	content := map[string]string{}
+	for _, file := range files {
+		content[file.Path] = string(file.Content)
+	}
+
+	if primary := content["primary/setup_primary.sh"]; !strings.Contains(primary, "'replica_1'") || strings.Contains(primary, "'replica_2'") {
+		t.Errorf("setup_primary.sh should only create slots for direct replicas:\n%s", primary)
+	}
+
+	upstream := content["replica-10.0.0.2/setup_replication.sh"]
+	if !strings.Contains(upstream, `UPSTREAM_HOST="10.0.0.1"`) || !strings.Contains(upstream, "pg_create_physical_replication_slot('replica_2')") {
+		t.Errorf("upstream setup_replication.sh should stream from the primary and create replica_2:\n%s", upstream)
+	}
+	if hba, ok := content["replica-10.0.0.2/pg_hba.conf.custom"]; !ok || !strings.Contains(hba, "host    replication    replicator    10.0.0.3/32") {
+		t.Errorf("upstream pg_hba.conf.custom = %q", hba)
+	}
+
+	cascaded := content["replica-10.0.0.3/setup_replication.sh"]
+	if !strings.Contains(cascaded, `UPSTREAM_HOST="10.0.0.2"`) || strings.Contains(cascaded, "pg_create_physical_replication_slot") {
+		t.Errorf("cascaded setup_replication.sh should stream from 10.0.0.2:\n%s", cascaded)
+	}
+	if _, ok := content["replica-10.0.0.3/pg_hba.conf.custom"]; ok {
+		t.Error("a replica without downstreams should not get pg_hba.conf.custom")
+	}
+	if pgpass := content["replica-10.0.0.3/pgpass"]; !strings.Contains(pgpass, "10.0.0.2:5432:*:replicator:secret") {
+		t.Errorf("cascaded pgpass should include the upstream:\n%s", pgpass)
+	}
+	if health := content["replica-10.0.0.3/health_check.sh"]; !strings.Contains(health, "cascades from 10.0.0.2 and is not promoted automatically") || strings.Contains(health, "perform_promotion;") {
+		t.Errorf("cascaded health_check.sh should never promote:\n%s", health)
+	}
+	if health := content["replica-10.0.0.2/health_check.sh"]; !strings.Contains(health, "perform_promotion;") {
+		t.Errorf("upstream health_check.sh should still promote:\n%s", health)
+	}
+}
+
+func TestDelayedReplicaFiles(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Options.PromoteOnFailure = true
+	cfg.Replicas[0].RecoveryMinApplyDelay = "1h"
+
+	files, err := New(cfg, nil).Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	content := map[string]string{}
+	for _, file := range files {
+		content[file.Path] = string(file.Content)
+	}
+
+	if setup := content["replica-10.0.0.2/setup_replication.sh"]; !strings.Contains(setup, "recovery_min_apply_delay = '1h'") {
+		t.Errorf("setup_replication.sh should set recovery_min_apply_delay:\n%s", setup)
+	}
+	if health := content["replica-10.0.0.2/health_check.sh"]; strings.Contains(health, "INITIATING: Auto-promotion") {
+		t.Errorf("health_check.sh should never promote a delayed replica:\n%s", health)
+	}
+}
+
+func TestSynchronousStandbyNames(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Cluster.Name = "orders"
+	second := cfg.Replicas[0]
+	second.Host = "10.0.0.3"
+	second.ReplicationSlot = "replica_2"
+	cfg.Replicas = append(cfg.Replicas, second)
+	cfg.Replicas[0].SyncMode = "sync"
+	cfg.Replicas[1].SyncMode = "sync"
+	cfg.Options.SyncQuorum = config.SyncQuorum{Method: "any", NumSync: 1}
+
+	files, err := New(cfg, nil).Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	content := map[string]string{}
+	for _, file := range files {
+		content[file.Path] = string(file.Content)
+	}
+
+	want := `synchronous_standby_names = 'ANY 1 ("orders_replica_1", "orders_replica_2")'`
+	if conf := content["primary/postgresql.conf.custom"]; !strings.Contains(conf, want) {
+		t.Errorf("postgresql.conf.custom should contain %s:\n%s", want, conf)
+	}
+	if setup := content["replica-10.0.0.3/setup_replication.sh"]; !strings.Contains(setup, `APPLICATION_NAME="orders_replica_2"`) {
+		t.Errorf("setup_replication.sh should connect with the matching application_name:\n%s", setup)
+	}
+
+	cfg.Replicas[0].SyncMode = "async"
+	cfg.Replicas[1].SyncMode = "async"
+	files, err = New(cfg, nil).Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	for _, file := range files {
+		if file.Path == "primary/postgresql.conf.custom" && strings.Contains(string(file.Content), "synchronous_standby_names") {
+			t.Errorf("postgresql.conf.custom without sync replicas:\n%s", file.Content)
+		}
+	}
+}
+
+func TestLogicalSubscriberFiles(t *testing.T) {
+	cfg := testConfig(t)
+	copyData := false
+	cfg.LogicalSubscribers = []config.LogicalSubscriber{{
+		Name:   "analytics",
+		Host:   "10.0.2.10",
+		Port:   5432,
+		DbName: "warehouse",
+		Publications: []config.Publication{
+			{Name: "orders_pub", Tables: []string{"orders", "sales.customers"}, Schemas: []string{"billing"}},
+		},
+		Subscription: config.SubscriptionOptions{CopyData: &copyData},
+	}}
+
+	files, err := New(cfg, nil).Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	byPath := map[string]File{}
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+
+	publications := string(byPath["primary/publications.sql"].Content)
+	for _, want := range []string{
+		"CREATE PUBLICATION orders_pub FOR TABLE orders, sales.customers, TABLES IN SCHEMA billing;",
+		"GRANT USAGE ON SCHEMA sales TO replicator;",
+		"GRANT SELECT ON ALL TABLES IN SCHEMA billing TO replicator;",
+	} {
+		if !strings.Contains(publications, want) {
+			t.Errorf("publications.sql should contain %q:\n%s", want, publications)
+		}
+	}
+	if hba := string(byPath["primary/pg_hba.conf.custom"].Content); !strings.Contains(hba, "host    postgres    replicator    10.0.2.10/32    md5") {
+		t.Errorf("pg_hba.conf.custom should allow the subscriber:\n%s", hba)
+	}
+	if setup := string(byPath["primary/setup_primary.sh"].Content); !strings.Contains(setup, "-f ./publications.sql") {
+		t.Errorf("setup_primary.sh should create the publications:\n%s", setup)
+	}
+
+	subscription := byPath["logical/analytics/subscription.sql"]
+	if subscription.Mode != 0600 {
+		t.Errorf("subscription.sql mode = %v, want 0600", subscription.Mode)
+	}
+	for _, want := range []string{
+		`CONNECTION 'host=''10.0.0.1'' port=5432 dbname=''postgres'' user=''replicator'' password=''secret'' application_name=''analytics'''`,
+		"PUBLICATION orders_pub",
+		"WITH (copy_data = false, binary = false, streaming = false);",
+	} {
+		if !strings.Contains(string(subscription.Content), want) {
+			t.Errorf("subscription.sql should contain %q:\n%s", want, subscription.Content)
+		}
+	}
+	if _, ok := byPath["logical/analytics/status.sql"]; !ok {
+		t.Error("no status.sql generated for the subscriber")
+	}
+}
+
+func TestSlotRetentionFiles(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Options.MaxSlotWalKeepSize = "20GB"
+	cfg.Options.SlotWalWarningSize = "5GB"
+
+	files, err := New(cfg, nil).Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	content := map[string]string{}
+	for _, file := range files {
+		content[file.Path] = string(file.Content)
+	}
+
+	if conf := content["primary/postgresql.conf.custom"]; !strings.Contains(conf, "max_slot_wal_keep_size = 20GB") {
+		t.Errorf("postgresql.conf.custom should set max_slot_wal_keep_size:\n%s", conf)
+	}
+	if script := content["primary/reconcile_slots.sh"]; !strings.Contains(script, "EXPECTED_SLOTS=('replica_1')") {
+		t.Errorf("reconcile_slots.sh should expect the configured slots:\n%s", script)
+	}
+	if health := content["replica-10.0.0.2/health_check.sh"]; !strings.Contains(health, `SLOT_WAL_WARNING_SIZE="5GB"`) {
+		t.Errorf("health_check.sh should warn at slot_wal_warning_size:\n%s", health)
+	}
+
+	cfg.Options.SlotWalWarningSize = "2048"
+	files, err = New(cfg, nil).Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	for _, file := range files {
+		if file.Path == "replica-10.0.0.2/health_check.sh" && !strings.Contains(string(file.Content), `SLOT_WAL_WARNING_SIZE="2048MB"`) {
+			t.Errorf("health_check.sh should read a unitless slot_wal_warning_size as MB:\n%s", file.Content)
+		}
+	}
+}
+
+func TestHAProxyFiles(t *testing.T) {
+	cfg := testConfig(t)
+	delayed := cfg.Replicas[0]
+	delayed.Host = "10.0.0.3"
+	delayed.ReplicationSlot = "replica_2"
+	delayed.RecoveryMinApplyDelay = "1h"
+	cfg.Replicas = append(cfg.Replicas, delayed)
+	cfg.Routing = &config.Routing{HAProxy: config.HAProxyConfig{Enabled: true}}
+	config.ApplyDefaults(cfg)
+
+	files, err := New(cfg, nil).Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	content := map[string]string{}
+	for _, file := range files {
+		content[file.Path] = string(file.Content)
+	}
+
+	haproxy := content["haproxy/haproxy.cfg"]
+	readWrite, readOnly, _ := strings.Cut(haproxy, "listen replicas")
+	for _, want := range []string{"server primary 10.0.0.1:5432 check port 8008", "server replica-10.0.0.3 10.0.0.3:5432 check port 8008", "bind *:5000"} {
+		if !strings.Contains(readWrite, want) {
+			t.Errorf("read-write section should contain %q:\n%s", want, haproxy)
+		}
+	}
+	if !strings.Contains(readOnly, "server replica-10.0.0.2 10.0.0.2:5432") || strings.Contains(readOnly, "10.0.0.3") || strings.Contains(readOnly, "10.0.0.1") {
+		t.Errorf("read-only section should only route to the undelayed replica:\n%s", haproxy)
+	}
+
+	for _, node := range []string{"primary", "replica-10.0.0.2", "replica-10.0.0.3"} {
+		for _, file := range []string{"role_check.sh", "ha-postgres-role.socket", "ha-postgres-role@.service"} {
+			if _, ok := content[node+"/"+file]; !ok {
+				t.Errorf("%s/%s was not generated", node, file)
+			}
+		}
+	}
+	if service := content["primary/ha-postgres-role@.service"]; !strings.Contains(service, "ExecStart=/opt/ha-syncgen/primary/role_check.sh") {
+		t.Errorf("role check service = %s", service)
+	}
+}
+
+func TestVIPFiles(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Routing = &config.Routing{VIP: &config.VIPConfig{Address: "10.0.0.100/24", Interface: "eth0"}}
+	config.ApplyDefaults(cfg)
+
+	files, err := New(cfg, nil).Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	content := map[string]string{}
+	for _, file := range files {
+		content[file.Path] = string(file.Content)
+	}
+
+	for node, priority := range map[string]string{"primary": "priority 150", "replica-10.0.0.2": "priority 100"} {
+		conf := content[node+"/keepalived.conf"]
+		for _, want := range []string{priority, "virtual_router_id 51", "10.0.0.100/24 dev eth0", `script "/opt/ha-syncgen/` + node + `/vip_check.sh"`, `notify "/opt/ha-syncgen/` + node + `/vip_notify.sh"`} {
+			if !strings.Contains(conf, want) {
+				t.Errorf("%s/keepalived.conf should contain %q:\n%s", node, want, conf)
+			}
+		}
+		if _, ok := content[node+"/vip_notify.sh"]; !ok {
+			t.Errorf("%s/vip_notify.sh was not generated", node)
+		}
+	}
+	if check := content["replica-10.0.0.2/vip_check.sh"]; !strings.Contains(check, `[ "$IN_RECOVERY" = "f" ]`) {
+		t.Errorf("vip_check.sh should only pass on the primary:\n%s", check)
+	}
+
+	// A named cluster installs its own include instead of replacing keepalived.conf
+	cfg.Cluster.Name = "orders"
+	bundles, err := New(cfg, nil).Bundles()
+	if err != nil {
+		t.Fatalf("Bundles() error = %v", err)
+	}
+	install := string(bundles[0].Files[0].Content)
+	if !strings.Contains(install, "install -m 0644 keepalived.conf /etc/keepalived/conf.d/ha_syncgen_orders.conf") ||
+		!strings.Contains(install, "echo 'include /etc/keepalived/conf.d/*.conf' >> /etc/keepalived/keepalived.conf") ||
+		strings.Contains(install, "keepalived.conf /etc/keepalived/keepalived.conf") {
+		t.Errorf("install.sh should install keepalived.conf as a per-cluster include:\n%s", install)
+	}
+}
+
+func TestPgBouncerFiles(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Options.PromoteOnFailure = true
+	cfg.Pooling = &config.Pooling{PgBouncer: config.PgBouncerConfig{Enabled: true, PoolMode: "session", Binary: "/usr/local/bin/pgbouncer"}}
+	config.ApplyDefaults(cfg)
+
+	g := New(cfg, nil)
+	files, err := g.Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	content := map[string]File{}
+	for _, file := range files {
+		content[file.Path] = file
+	}
+
+	ini := string(content["replica-10.0.0.2/pgbouncer.ini"].Content)
+	for _, want := range []string{"postgres = host=10.0.0.1 port=5432 dbname=postgres", "listen_port = 6432", "pool_mode = session", "auth_user = postgres"} {
+		if !strings.Contains(ini, want) {
+			t.Errorf("pgbouncer.ini should contain %q:\n%s", want, ini)
+		}
+	}
+	secret := "SCRAM-SHA-256$4096:XsIwXX/CFQKrt547yCkrUA==$Rmucyc4jNUFf1Z6frCgIORzER0ZxY7CVlIEUtK5vTQA=:zLKyOnEZSxSQAOkQ/rb1Ze2ISRR2s2XYAriUvi7f200="
+	userlist := content["primary/userlist.txt"]
+	if userlist.Mode != 0600 || string(userlist.Content) != "\"postgres\" \""+secret+"\"\n" {
+		t.Errorf("userlist.txt = mode %v:\n%s", userlist.Mode, userlist.Content)
+	}
+	if !strings.Contains(ini, "auth_type = scram-sha-256") {
+		t.Errorf("pgbouncer.ini should use SCRAM:\n%s", ini)
+	}
+	if setup := string(content["primary/setup_primary.sh"].Content); !strings.Contains(setup, "ALTER ROLE postgres PASSWORD '"+secret+"';") {
+		t.Errorf("setup_primary.sh should store the userlist secret:\n%s", setup)
+	}
+	hba := string(content["primary/pg_hba.conf.custom"].Content)
+	for _, host := range []string{"10.0.0.1/32", "10.0.0.2/32"} {
+		if !strings.Contains(hba, "host    postgres    postgres    "+host+"    scram-sha-256") {
+			t.Errorf("pg_hba.conf.custom should allow PgBouncer on %s:\n%s", host, hba)
+		}
+	}
+	if service := string(content["primary/ha-postgres-pgbouncer.service"].Content); !strings.Contains(service, "ExecStart=/usr/local/bin/pgbouncer /opt/ha-syncgen/primary/pgbouncer.ini") {
+		t.Errorf("PgBouncer unit should start pooling.pgbouncer.binary:\n%s", service)
+	}
+	bundles, err := g.Bundles()
+	if err != nil {
+		t.Fatalf("Bundles() error = %v", err)
+	}
+	if install := string(bundles[0].Files[0].Content); !strings.Contains(install, "if [ ! -x /usr/local/bin/pgbouncer ]; then") {
+		t.Errorf("install.sh should check for the PgBouncer binary:\n%s", install)
+	}
+	health := string(content["replica-10.0.0.2/health_check.sh"].Content)
+	if !strings.Contains(health, `pgbouncer_repoint.sh" "$REPLICA_HOST" "5432"`) {
+		t.Errorf("health_check.sh should repoint PgBouncer after promotion:\n%s", health)
+	}
+	// ProtectSystem=strict leaves the install directory read-only unless the
+	// health check unit grants write access to pgbouncer.ini's directory
+	iniDir := "/opt/ha-syncgen/replica-10.0.0.2"
+	if repoint := string(content["replica-10.0.0.2/pgbouncer_repoint.sh"].Content); !strings.Contains(repoint, `INI="`+iniDir+`/pgbouncer.ini"`) {
+		t.Errorf("pgbouncer_repoint.sh should rewrite the ini in %s:\n%s", iniDir, repoint)
+	}
+	unit := string(content["replica-10.0.0.2/ha-postgres-health.service"].Content)
+	_, paths, _ := strings.Cut(unit, "\nReadWritePaths=")
+	paths, _, _ = strings.Cut(paths, "\n")
+	if !slices.Contains(strings.Fields(paths), iniDir) {
+		t.Errorf("health check unit should be able to write %s:\n%s", iniDir, unit)
+	}
+
+	// Every node follows the primary, not just the one that was promoted
+	for _, node := range []string{"primary", "replica-10.0.0.2"} {
+		for _, file := range []string{"ha-postgres-pgbouncer-follow.service", "ha-postgres-pgbouncer-follow.timer"} {
+			if _, ok := content[node+"/"+file]; !ok {
+				t.Errorf("%s/%s was not generated", node, file)
+			}
+		}
+		if pgpass := content[node+"/pgbouncer.pgpass"]; pgpass.Mode != 0600 || !strings.Contains(string(pgpass.Content), "*:*:postgres:postgres:secret") {
+			t.Errorf("%s/pgbouncer.pgpass = mode %v:\n%s", node, pgpass.Mode, pgpass.Content)
+		}
+	}
+	repoint := string(content["primary/pgbouncer_repoint.sh"].Content)
+	if !strings.Contains(repoint, "for node in '10.0.0.1:5432' '10.0.0.2:5432'; do") {
+		t.Errorf("pgbouncer_repoint.sh should probe every node:\n%s", repoint)
+	}
+	// The health check and the follow timer must not rewrite the ini at once
+	if lock, rewrite := strings.Index(repoint, "flock -w 30 9"), strings.Index(repoint, `> "$INI"`); lock < 0 || lock > rewrite {
+		t.Errorf("pgbouncer_repoint.sh should lock the ini before rewriting it:\n%s", repoint)
+	}
+}
+
+func TestScramSecret(t *testing.T) {
+	salt := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	want := "SCRAM-SHA-256$4096:AAECAwQFBgcICQoLDA0ODw==$THoPhoTAuqyoQsK4dUHncUzgfD8fdmhsgKZhWVqNP5U=:7YiHMMi2OcXGRogub03Ek06JRZ9bkhTOdCzHa5iPLiQ="
+	if got := scramSecret("secret", salt); got != want {
+		t.Errorf("scramSecret() = %s, want %s", got, want)
+	}
+}
+
+func TestBackupFiles(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Backup = &config.Backup{Tool: "pgbackrest", DiffSchedule: "Mon..Sat *-*-* 01:00:00"}
+	config.ApplyDefaults(cfg)
+
+	files, err := New(cfg, nil).Render()
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	content := map[string]File{}
+	for _, file := range files {
+		content[file.Path] = file
+	}
+
+	conf := string(content["primary/postgresql.conf.custom"].Content)
+	if !strings.Contains(conf, "archive_command = 'pgbackrest --stanza=main archive-push %p'") {
+		t.Errorf("postgresql.conf should archive with pgBackRest:\n%s", conf)
+	}
+	if setup := string(content["primary/setup_primary.sh"].Content); !strings.Contains(setup, "pgbackrest --stanza=main stanza-create") {
+		t.Errorf("setup_primary.sh should create the stanza:\n%s", setup)
+	}
+	replication := string(content["replica-10.0.0.2/setup_replication.sh"].Content)
+	if !strings.Contains(replication, `restore_command = '\''pgbackrest --stanza=main archive-get %f "%p"'\''`) {
+		t.Errorf("setup_replication.sh should set restore_command:\n%s", replication)
+	}
+
+	pgbackrest := content["replica-10.0.0.2/pgbackrest.conf"]
+	if pgbackrest.Mode != 0600 || !strings.Contains(string(pgbackrest.Content), "repo1-path=/var/lib/pgbackup") {
+		t.Errorf("pgbackrest.conf = mode %v:\n%s", pgbackrest.Mode, pgbackrest.Content)
+	}
+	for _, timer := range []string{"full", "diff"} {
+		unit := string(content["primary/ha-postgres-backup-"+timer+".timer"].Content)
+		if !strings.Contains(unit, "Unit=ha-postgres-backup@"+timer+".service") {
+			t.Errorf("%s timer = %s", timer, unit)
+		}
+	}
+}
+
+func TestRestorePlan(t *testing.T) {
+	cfg := testConfig(t)
+	if _, err := New(cfg, nil).RestorePlan(RestoreTarget{LSN: "0/3000060"}); err == nil {
+		t.Error("RestorePlan() without backups should fail")
+	}
+
+	cfg.Backup = &config.Backup{Tool: "wal-g"}
+	config.ApplyDefaults(cfg)
+	tests := []struct {
+		name    string
+		target  RestoreTarget
+		want    string
+		wantErr string
+	}{
+		{name: "time with offset", target: RestoreTarget{Time: "2024-05-01T14:30:00Z"}, want: "recovery_target_time = '2024-05-01 14:30:00+00:00'"},
+		{name: "server time", target: RestoreTarget{Node: "10.0.0.2", Time: "2024-05-01 14:30:00"}, want: "recovery_target_time = '2024-05-01 14:30:00'"},
+		{name: "lsn", target: RestoreTarget{LSN: "0/3000060"}, want: "recovery_target_lsn = '0/3000060'"},
+		{name: "invalid time", target: RestoreTarget{Time: "yesterday"}, wantErr: "invalid target time"},
+		{name: "unknown node", target: RestoreTarget{Node: "10.0.0.9", LSN: "0/1"}, wantErr: "node '10.0.0.9' is not the primary or a configured replica"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := New(cfg, nil).RestorePlan(tt.target)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RestorePlan() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RestorePlan() error = %v", err)
+			}
+			content := string(script.Content)
+			for _, want := range []string{tt.want, "restore_command = 'wal-g --config /etc/wal-g/ha-syncgen.json wal-fetch %f %p'", "recovery.signal"} {
+				if !strings.Contains(content, want) {
+					t.Errorf("restore.sh should contain %q:\n%s", want, content)
+				}
+			}
+		})
+	}
+
+	// Restoring the primary must not leave the replicas free to promote themselves
+	primary, err := New(cfg, nil).RestorePlan(RestoreTarget{LSN: "0/3000060"})
+	if err != nil {
+		t.Fatalf("RestorePlan() error = %v", err)
+	}
+	for _, want := range []string{"ssh 10.0.0.2 sudo systemctl stop ha-postgres-health.timer", "ssh 10.0.0.2 sudo systemctl start ha-postgres-health.timer"} {
+		if !strings.Contains(string(primary.Content), want) {
+			t.Errorf("primary restore.sh should contain %q:\n%s", want, primary.Content)
+		}
+	}
+	replica, err := New(cfg, nil).RestorePlan(RestoreTarget{Node: "10.0.0.2", LSN: "0/3000060"})
+	if err != nil {
+		t.Fatalf("RestorePlan() error = %v", err)
+	}
+	if content := string(replica.Content); !strings.Contains(content, "systemctl stop ha-postgres-health.timer || true") || strings.Contains(content, "ssh ") {
+		t.Errorf("replica restore.sh should only stop its own health check:\n%s", content)
+	}
+
+	// Only the primary is promoted; a replica pauses so it never becomes a
+	// second writable node while the primary runs
+	for name, script := range map[string]File{"primary": primary, "replica": replica} {
+		want := map[string]string{"primary": "promote", "replica": "pause"}[name]
+		if content := string(script.Content); !strings.Contains(content, "recovery_target_action = '"+want+"'") {
+			t.Errorf("%s restore.sh should use recovery_target_action '%s':\n%s", name, want, content)
+		}
+	}
+	if content := string(replica.Content); strings.Contains(content, "ALTER SYSTEM RESET") || !strings.Contains(content, "pg_is_wal_replay_paused()") {
+		t.Errorf("replica restore.sh should wait for replay to pause:\n%s", content)
+	}
+}
+
+func TestArchiveOptions(t *testing.T) {
+	render := func(modify func(cfg *config.Config)) map[string]string {
+		t.Helper()
+		cfg := testConfig(t)
+		modify(cfg)
+		files, err := New(cfg, nil).Render()
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		content := map[string]string{}
+		for _, file := range files {
+			content[file.Path] = string(file.Content)
+		}
+		return content
+	}
+
+	content := render(func(cfg *config.Config) {
+		cfg.Primary.DataDirectory = "/srv/pg/16/main"
+		cfg.Options.Archive.Directory = ""
+		cfg.Options.Archive.Timeout = "60s"
+		config.ApplyDefaults(cfg)
+	})
+	conf := content["primary/postgresql.conf.custom"]
+	for _, want := range []string{"archive_mode = on", "archive_command = 'test ! -f /srv/pg/16/archive/%f && cp %p /srv/pg/16/archive/%f'", "archive_timeout = 60s"} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("postgresql.conf should contain %q:\n%s", want, conf)
+		}
+	}
+	if setup := content["primary/setup_primary.sh"]; !strings.Contains(setup, "install -d -m 0700 -o postgres -g postgres /srv/pg/16/archive") {
+		t.Errorf("setup_primary.sh should create the archive directory:\n%s", setup)
+	}
+
+	disabled := false
+	content = render(func(cfg *config.Config) { cfg.Options.Archive.Enabled = &disabled })
+	if conf := content["primary/postgresql.conf.custom"]; !strings.Contains(conf, "archive_mode = off") || strings.Contains(conf, "archive_command") {
+		t.Errorf("postgresql.conf with archiving disabled:\n%s", conf)
+	}
+	if setup := content["primary/setup_primary.sh"]; strings.Contains(setup, "archive") {
+		t.Errorf("setup_primary.sh should not create an archive directory:\n%s", setup)
+	}
+	if health := content["replica-10.0.0.2/health_check.sh"]; !strings.Contains(health, "ALTER SYSTEM SET archive_mode = off;") {
+		t.Errorf("health_check.sh should keep archiving off after promotion:\n%s", health)
+	}
+
+	content = render(func(cfg *config.Config) { cfg.Options.Archive.Command = "/usr/local/bin/ship-wal %p %f" })
+	if conf := content["primary/postgresql.conf.custom"]; !strings.Contains(conf, "archive_command = '/usr/local/bin/ship-wal %p %f'") {
+		t.Errorf("postgresql.conf should use the archive command override:\n%s", conf)
+	}
+}
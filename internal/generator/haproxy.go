@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"net"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// haproxyServer is one PostgreSQL node in an HAProxy backend
+type haproxyServer struct {
+	Name    string
+	Address string
+}
+
+// generateHAProxyConfig creates haproxy/haproxy.cfg, routing the read-write
+// port to whichever node reports itself as primary and the read-only port to
+// the replicas
+func (g *Generator) generateHAProxyConfig() error {
+	haproxyTmpl, err := parseTemplateByName("haproxy.cfg.tmpl")
+	if err != nil {
+		return err
+	}
+
+	primary := haproxyServer{Name: "primary", Address: net.JoinHostPort(g.config.Primary.Host, strconv.Itoa(g.config.Primary.Port))}
+	servers := []haproxyServer{primary}
+	var readOnly []haproxyServer
+	for _, replica := range g.config.Replicas {
+		server := haproxyServer{Name: replicaDirName(replica.Host), Address: net.JoinHostPort(replica.Host, strconv.Itoa(replica.Port))}
+		servers = append(servers, server)
+		// Delayed replicas serve deliberately stale data, so reads never go to them
+		if !replica.Delayed() {
+			readOnly = append(readOnly, server)
+		}
+	}
+
+	data := map[string]interface{}{
+		"Cluster":         g.config.Cluster,
+		"HAProxy":         g.config.Routing.HAProxy,
+		"Servers":         servers,
+		"ReadOnlyServers": readOnly,
+	}
+	return g.renderTemplate(haproxyTmpl, "haproxy", "haproxy.cfg", data)
+}
+
+// generateRoleCheck creates the role check HAProxy queries on a node: a script
+// answering HTTP requests and the socket-activated systemd units that run it
+func (g *Generator) generateRoleCheck(nodeDir, host string, port int) error {
+	unitName := g.config.RoleUnitName()
+	data := map[string]interface{}{
+		"Node":      nodeDir,
+		"Host":      host,
+		"Port":      port,
+		"Cluster":   g.config.Cluster,
+		"CheckPort": g.config.Routing.HAProxy.CheckPort,
+		"NodeDir":   path.Join(g.config.InstallDirectory(), nodeDir),
+		"UnitName":  unitName,
+	}
+	files := []struct {
+		template string
+		filename string
+	}{
+		{"role_check.sh.tmpl", "role_check.sh"},
+		{"ha-postgres-role.socket.tmpl", unitName + ".socket"},
+		{"ha-postgres-role@.service.tmpl", unitName + "@.service"},
+	}
+	for _, file := range files {
+		tmpl, err := parseTemplateByName(file.template)
+		if err != nil {
+			return err
+		}
+		if err := g.renderFile(tmpl, data, filepath.Join(nodeDir, file.filename), file.filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
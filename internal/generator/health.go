@@ -1,25 +1,37 @@
 package generator
 
 import (
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
 	"path/filepath"
-	"syncgen/internal/config"
+	"strconv"
 )
 
 // generateHealthCheckScript creates a health check script for monitoring primary PostgreSQL status using a template
 func (g *Generator) generateHealthCheckScript(replica config.Replica, replicaDir string) error {
-	tmplDir, tmplErr := getTemplateDirectory()
-	if tmplErr != nil {
-		return tmplErr
-	}
-	healthTmpl, err := parseTemplateByName(tmplDir, "health_check.sh.tmpl")
+	healthTmpl, err := parseTemplateByName("health_check.sh.tmpl")
 	if err != nil {
 		return err
 	}
 	data := map[string]interface{}{
-		"Replica": replica,
-		"Primary": g.config.Primary,
-		"Options": g.config.Options,
+		"Replica":            replica,
+		"Primary":            g.config.Primary,
+		"Options":            g.config.Options,
+		"Notifications":      g.config.Notifications,
+		"Cluster":            g.config.Cluster,
+		"LogDirectory":       g.config.LogDirectory(),
+		"PgBouncer":          g.config.PgBouncerEnabled(),
+		"Archive":            g.config.ArchiveEnabled(),
+		"SlotWalWarningSize": withSizeUnit(g.config.Options.SlotWalWarningSize),
 	}
 	outputFile := filepath.Join(replicaDir, "health_check.sh")
-	return executeTemplateToFile(healthTmpl, data, outputFile, "health_check.sh")
+	return g.renderFile(healthTmpl, data, outputFile, "health_check.sh")
+}
+
+// withSizeUnit appends MB to a unitless WAL size so it means the same to
+// pg_size_bytes as it does in postgresql.conf
+func withSizeUnit(size string) string {
+	if _, err := strconv.Atoi(size); err == nil {
+		return size + "MB"
+	}
+	return size
 }
@@ -1,38 +1,93 @@
 package generator
 
 import (
+	"bytes"
+	"embed"
+	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/template"
 )
 
-// executeTemplateToFile is a helper to execute a template with data and write to a file, with error context
-func executeTemplateToFile(tmpl *template.Template, data interface{}, outputPath string, templateName string) error {
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+// templateFuncs are the helper functions available to every template
+var templateFuncs = template.FuncMap{
+	"shellQuote":  ShellQuote,
+	"join":        strings.Join,
+	"hbaAddress":  hbaAddress,
+	"pgpassField": pgpassField,
+	"json":        toJSON,
+}
 
-	if err := tmpl.Execute(file, data); err != nil {
-		return fmt.Errorf("failed to execute %s template: %v", templateName, err)
+// ShellQuote wraps a value in single quotes so it can be embedded safely in a shell script
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// hbaAddress formats a host for the address column of pg_hba.conf: a single-host
+// CIDR for IP addresses, or the name itself for DNS names
+func hbaAddress(host string) string {
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return host
+	case ip.To4() != nil:
+		return host + "/32"
+	default:
+		return host + "/128"
 	}
+}
+
+// pgpassField escapes a value for a field of a .pgpass file, where : and \ are
+// separators and escape characters (IPv6 addresses contain colons)
+func pgpassField(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ":", `\:`).Replace(value)
+}
+
+// toJSON formats a value as indented JSON for configuration files
+func toJSON(value any) (string, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	return string(data), err
+}
 
-	// Make scripts executable if .sh
-	if filepath.Ext(outputPath) == ".sh" {
-		if err := os.Chmod(outputPath, 0755); err != nil {
-			return fmt.Errorf("failed to make script executable: %w", err)
-		}
+// renderFile executes a template and records the result as a generated file at
+// outputPath, relative to the output root
+func (g *Generator) renderFile(tmpl *template.Template, data interface{}, outputPath string, templateName string) error {
+	var content bytes.Buffer
+	if err := tmpl.Execute(&content, data); err != nil {
+		return fmt.Errorf("failed to execute %s template: %v", templateName, err)
 	}
 
+	g.files = append(g.files, File{Path: filepath.ToSlash(outputPath), Mode: fileMode(outputPath), Content: content.Bytes(), Template: tmpl.Name()})
 	return nil
 }
 
-// parseTemplateByName loads and parses a template by name from the given directory
-func parseTemplateByName(templateDirectory, templateName string) (*template.Template, error) {
-	tmplPath := filepath.Join(templateDirectory, templateName)
-	tmpl, err := template.ParseFiles(tmplPath)
+// fileMode returns the permissions for a generated file: scripts are
+// executable and credentials are readable by their owner only
+func fileMode(name string) os.FileMode {
+	switch {
+	case filepath.Base(name) == notifyFile, filepath.Base(name) == datadogInstallFile:
+		return 0700
+	case filepath.Ext(name) == ".sh":
+		return 0755
+	case filepath.Base(name) == pgpassFile, filepath.Base(name) == subscriptionFile, filepath.Base(name) == userlistFile, filepath.Base(name) == pgbouncerPgpassFile,
+		filepath.Base(name) == pgbackrestFile, filepath.Base(name) == walgFile, filepath.Base(name) == datadogConfFile:
+		return 0600
+	default:
+		return 0644
+	}
+}
+
+// templates are compiled into the binary so generation works outside the source tree
+//
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// parseTemplateByName loads and parses an embedded template by name
+func parseTemplateByName(templateName string) (*template.Template, error) {
+	tmpl, err := template.New(templateName).Funcs(templateFuncs).ParseFS(templates, "templates/"+templateName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse %s template: %w", templateName, err)
 	}
@@ -0,0 +1,130 @@
+package generator
+
+import (
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"path"
+	"slices"
+	"strings"
+)
+
+// subscriptionFile holds a subscriber's CREATE SUBSCRIPTION, including the
+// replication password in its connection string
+const subscriptionFile = "subscription.sql"
+
+// logicalPublication is a publication with its FOR clause. UsageSchemas are
+// the published schemas and the schemas of published tables, which the
+// replication user needs USAGE on.
+type logicalPublication struct {
+	Name         string
+	Target       string
+	Tables       []string
+	Schemas      []string
+	UsageSchemas []string
+}
+
+// generateLogicalFiles creates the primary's publications and a subscription
+// and status query for every logical subscriber under logical/<name>/
+func (g *Generator) generateLogicalFiles() error {
+	publicationsTmpl, err := parseTemplateByName("publications.sql.tmpl")
+	if err != nil {
+		return err
+	}
+	primaryStatusTmpl, err := parseTemplateByName("logical_status_primary.sql.tmpl")
+	if err != nil {
+		return err
+	}
+	subscriptionTmpl, err := parseTemplateByName("subscription.sql.tmpl")
+	if err != nil {
+		return err
+	}
+	statusTmpl, err := parseTemplateByName("logical_status.sql.tmpl")
+	if err != nil {
+		return err
+	}
+
+	primary := g.config.Primary
+	primaryData := map[string]interface{}{
+		"Primary":      primary,
+		"Publications": g.logicalPublications(),
+		"Subscribers":  g.config.LogicalSubscribers,
+	}
+	if err := g.renderTemplate(publicationsTmpl, "primary", "publications.sql", primaryData); err != nil {
+		return err
+	}
+	if err := g.renderTemplate(primaryStatusTmpl, "primary", "logical_status.sql", primaryData); err != nil {
+		return err
+	}
+
+	for _, subscriber := range g.config.LogicalSubscribers {
+		names := make([]string, len(subscriber.Publications))
+		for i, publication := range subscriber.Publications {
+			names[i] = publication.Name
+		}
+		data := map[string]interface{}{
+			"Subscriber":   subscriber,
+			"Conninfo":     subscriptionConninfo(primary, subscriber),
+			"Publications": names,
+			"CopyData":     *subscriber.Subscription.CopyData,
+		}
+		dir := path.Join("logical", subscriber.Name)
+		if err := g.renderTemplate(subscriptionTmpl, dir, subscriptionFile, data); err != nil {
+			return err
+		}
+		if err := g.renderTemplate(statusTmpl, dir, "status.sql", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logicalPublications returns every publication once, in the order they are
+// first listed by a subscriber
+func (g *Generator) logicalPublications() []logicalPublication {
+	var publications []logicalPublication
+	seen := make(map[string]bool)
+	for _, subscriber := range g.config.LogicalSubscribers {
+		for _, publication := range subscriber.Publications {
+			if seen[publication.Name] {
+				continue
+			}
+			seen[publication.Name] = true
+
+			var targets []string
+			if len(publication.Tables) > 0 {
+				targets = append(targets, "TABLE "+strings.Join(publication.Tables, ", "))
+			}
+			if len(publication.Schemas) > 0 {
+				targets = append(targets, "TABLES IN SCHEMA "+strings.Join(publication.Schemas, ", "))
+			}
+			schemas := append([]string(nil), publication.Schemas...)
+			for _, table := range publication.Tables {
+				schema, _, qualified := strings.Cut(table, ".")
+				if !qualified {
+					schema = "public"
+				}
+				if !slices.Contains(schemas, schema) {
+					schemas = append(schemas, schema)
+				}
+			}
+			publications = append(publications, logicalPublication{
+				Name:         publication.Name,
+				Target:       strings.Join(targets, ", "),
+				Tables:       publication.Tables,
+				Schemas:      publication.Schemas,
+				UsageSchemas: schemas,
+			})
+		}
+	}
+	return publications
+}
+
+// subscriptionConninfo returns the connection string a subscriber uses to reach
+// the primary, as an SQL string literal
+func subscriptionConninfo(primary config.Primary, subscriber config.LogicalSubscriber) string {
+	quote := strings.NewReplacer(`\`, `\\`, "'", `\'`)
+	conninfo := fmt.Sprintf("host='%s' port=%d dbname='%s' user='%s' password='%s' application_name='%s'",
+		quote.Replace(primary.Host), primary.Port, quote.Replace(primary.DbName),
+		quote.Replace(primary.ReplicationUser), quote.Replace(primary.ReplicationPassword), subscriber.Name)
+	return "'" + strings.ReplaceAll(conninfo, "'", "''") + "'"
+}
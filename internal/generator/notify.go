@@ -0,0 +1,32 @@
+package generator
+
+import (
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"path/filepath"
+	"time"
+)
+
+// notifyFile embeds the SMTP password and webhook headers, so it is executable
+// by its owner only
+const notifyFile = "notify.sh"
+
+// generateNotifyScript creates the notification helpers sourced by the health check using a template
+func (g *Generator) generateNotifyScript(replica config.Replica, replicaDir string) error {
+	notifyTmpl, err := parseTemplateByName("notify.sh.tmpl")
+	if err != nil {
+		return err
+	}
+	retryDelay, err := time.ParseDuration(g.config.Notifications.RetryDelay)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"Replica":           replica,
+		"Primary":           g.config.Primary,
+		"Notifications":     g.config.Notifications,
+		"Cluster":           g.config.Cluster,
+		"RetryDelaySeconds": int(retryDelay.Seconds()),
+	}
+	outputFile := filepath.Join(replicaDir, notifyFile)
+	return g.renderFile(notifyTmpl, data, outputFile, notifyFile)
+}
@@ -0,0 +1,129 @@
+package generator
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// userlistFile holds the SCRAM secret PgBouncer authenticates with
+	userlistFile = "userlist.txt"
+	// pgbouncerPgpassFile holds the password pgbouncer_repoint.sh probes the nodes with
+	pgbouncerPgpassFile = "pgbouncer.pgpass"
+)
+
+// scramIterations matches PostgreSQL's default scram_iterations
+const scramIterations = 4096
+
+// generatePgBouncerFiles creates a node's PgBouncer configuration, pointed at
+// the primary, with its userlist, systemd unit and the script and timer that
+// repoint it after a failover
+func (g *Generator) generatePgBouncerFiles(nodeDir string) error {
+	primary := g.config.Primary
+	unitName := g.config.PgBouncerUnitName()
+	nodes := []string{fmt.Sprintf("%s:%d", primary.Host, primary.Port)}
+	for _, replica := range g.config.Replicas {
+		nodes = append(nodes, fmt.Sprintf("%s:%d", replica.Host, replica.Port))
+	}
+	data := map[string]interface{}{
+		"Node":       nodeDir,
+		"Nodes":      nodes,
+		"Cluster":    g.config.Cluster,
+		"Primary":    primary,
+		"PgBouncer":  g.config.Pooling.PgBouncer,
+		"NodeDir":    path.Join(g.config.InstallDirectory(), nodeDir),
+		"UnitName":   unitName,
+		"FollowUnit": unitName + "-follow",
+		"PgpassFile": pgbouncerPgpassFile,
+		"Userlist":   userlistEntry(primary.DbUser, g.pgbouncerSecret()),
+	}
+	files := []struct {
+		template string
+		filename string
+	}{
+		{"pgbouncer.ini.tmpl", "pgbouncer.ini"},
+		{"userlist.txt.tmpl", userlistFile},
+		{"pgbouncer.pgpass.tmpl", pgbouncerPgpassFile},
+		{"pgbouncer_repoint.sh.tmpl", "pgbouncer_repoint.sh"},
+		{"ha-postgres-pgbouncer.service.tmpl", unitName + ".service"},
+		{"ha-postgres-pgbouncer-follow.service.tmpl", unitName + "-follow.service"},
+		{"ha-postgres-pgbouncer-follow.timer.tmpl", unitName + "-follow.timer"},
+	}
+	for _, file := range files {
+		tmpl, err := parseTemplateByName(file.template)
+		if err != nil {
+			return err
+		}
+		if err := g.renderFile(tmpl, data, filepath.Join(nodeDir, file.filename), file.filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pgbouncerNodes lists the hosts whose PgBouncer connects to the primary, or
+// nil when PgBouncer is not enabled
+func (g *Generator) pgbouncerNodes() []string {
+	if !g.config.PgBouncerEnabled() {
+		return nil
+	}
+	hosts := []string{g.config.Primary.Host}
+	for _, replica := range g.config.Replicas {
+		hosts = append(hosts, replica.Host)
+	}
+	return hosts
+}
+
+// pgbouncerSecret returns the SCRAM secret in the userlist, or "" when
+// PgBouncer is not enabled
+func (g *Generator) pgbouncerSecret() string {
+	if !g.config.PgBouncerEnabled() {
+		return ""
+	}
+	primary := g.config.Primary
+	return scramSecret(primary.DbPassword, scramSalt(primary.DbUser, primary.DbPassword))
+}
+
+// userlistEntry returns a userlist.txt line for a user and its SCRAM secret,
+// so the plain password never reaches the nodes' PgBouncer files
+func userlistEntry(user, secret string) string {
+	quote := func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `""`) + `"` }
+	return quote(user) + " " + quote(secret)
+}
+
+// scramSalt derives a stable salt so regenerating the files does not change
+// the secret that setup_primary.sh stores for the user
+func scramSalt(user, password string) []byte {
+	mac := hmac.New(sha256.New, []byte(password))
+	mac.Write([]byte("ha-syncgen scram salt:" + user))
+	return mac.Sum(nil)[:16]
+}
+
+// scramSecret returns the SCRAM-SHA-256 secret PostgreSQL stores for a
+// password (RFC 5802, RFC 7677). PgBouncer can only log in to the server with
+// it when the server holds the identical secret.
+func scramSecret(password string, salt []byte) string {
+	hmacSum := func(key, data []byte) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(data)
+		return mac.Sum(nil)
+	}
+	// PBKDF2-HMAC-SHA-256 with a single output block
+	u := hmacSum([]byte(password), append(append([]byte{}, salt...), 0, 0, 0, 1))
+	salted := append([]byte{}, u...)
+	for i := 1; i < scramIterations; i++ {
+		u = hmacSum([]byte(password), u)
+		for j := range salted {
+			salted[j] ^= u[j]
+		}
+	}
+	storedKey := sha256.Sum256(hmacSum(salted, []byte("Client Key")))
+	serverKey := hmacSum(salted, []byte("Server Key"))
+	b64 := base64.StdEncoding.EncodeToString
+	return fmt.Sprintf("SCRAM-SHA-256$%d:%s$%s:%s", scramIterations, b64(salt), b64(storedKey[:]), b64(serverKey))
+}
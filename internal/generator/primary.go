@@ -1,13 +1,12 @@
 package generator
 
 import (
-	"path/filepath"
 	"text/template"
 )
 
 // generatePrimaryFiles creates configuration files for the primary PostgreSQL server
-func (g *Generator) generatePrimaryFiles(pgHbaTmpl, postgresqlConfTmpl, setupPrimaryTmpl *template.Template) error {
-	primaryDir := filepath.Join(g.outputDir, "primary")
+func (g *Generator) generatePrimaryFiles(pgHbaTmpl, postgresqlConfTmpl, setupPrimaryTmpl, reconcileSlotsTmpl *template.Template) error {
+	primaryDir := "primary"
 	specs := []FileSpec{
 		{
 			Tmpl:     postgresqlConfTmpl,
@@ -17,11 +16,17 @@ func (g *Generator) generatePrimaryFiles(pgHbaTmpl, postgresqlConfTmpl, setupPri
 				"WalLevel":            g.config.Options.WalLevel,
 				"HotStandby":          g.config.Options.HotStandby,
 				"SynchronousCommit":   g.config.Options.SynchronousCommit,
+				"SyncStandbyNames":    g.config.SynchronousStandbyNames(),
 				"MaxWalSenders":       g.config.Options.MaxWalSenders,
-				"MaxReplicationSlots": len(g.config.Replicas) + 2,
+				"MaxReplicationSlots": g.config.Options.MaxReplicationSlots,
 				"WalKeepSize":         g.config.Options.WalKeepSize,
+				"MaxSlotWalKeepSize":  g.config.Options.MaxSlotWalKeepSize,
 				"Port":                g.config.Primary.Port,
-				"HasMonitoring":       g.config.Monitoring.Datadog.Enabled,
+				"HasMonitoring":       g.config.DatadogEnabled(),
+				"ArchiveEnabled":      g.config.ArchiveEnabled(),
+				"ArchiveCommand":      g.archiveCommand(),
+				"ArchiveTimeout":      g.config.Options.Archive.Timeout,
+				"RestoreCommand":      g.restoreCommand(),
 			},
 		},
 		{
@@ -31,6 +36,10 @@ func (g *Generator) generatePrimaryFiles(pgHbaTmpl, postgresqlConfTmpl, setupPri
 			Data: map[string]interface{}{
 				"ReplicationUser": g.config.Primary.ReplicationUser,
 				"Replicas":        g.config.Replicas,
+				"DbName":          g.config.Primary.DbName,
+				"Subscribers":     g.config.LogicalSubscribers,
+				"DbUser":          g.config.Primary.DbUser,
+				"PgBouncerNodes":  g.pgbouncerNodes(),
 			},
 		},
 		{
@@ -44,11 +53,24 @@ func (g *Generator) generatePrimaryFiles(pgHbaTmpl, postgresqlConfTmpl, setupPri
 				"DbName":              g.config.Primary.DbName,
 				"ReplicationUser":     g.config.Primary.ReplicationUser,
 				"ReplicationPassword": g.config.Primary.ReplicationPassword,
-				"Replicas":            g.config.Replicas,
+				"Replicas":            g.config.Downstreams(""), // cascading replicas get slots on their upstream
 				"DataDirectory":       g.config.Primary.DataDirectory,
+				"Publications":        len(g.config.LogicalSubscribers) > 0,
+				"Backup":              g.backupSetup(),
+				"ArchiveDirectory":    g.archiveDirectory(),
+				"PgBouncerSecret":     g.pgbouncerSecret(),
 			},
 		},
 	}
+	specs = append(specs, FileSpec{
+		Tmpl:     reconcileSlotsTmpl,
+		Dir:      primaryDir,
+		Filename: "reconcile_slots.sh",
+		Data: map[string]interface{}{
+			"PrimaryPort": g.config.Primary.Port,
+			"Replicas":    g.config.Downstreams(""),
+		},
+	})
 	for _, spec := range specs {
 		if err := g.renderTemplate(spec.Tmpl, spec.Dir, spec.Filename, spec.Data); err != nil {
 			return err
@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// RestoreTarget is the point in time a restore plan recovers a node to. Exactly
+// one of Time and LSN is set.
+type RestoreTarget struct {
+	// Node is "primary" or the host of a replica
+	Node string
+	// Time is a timestamp, with a UTC offset or in the server's time zone
+	Time string
+	// LSN is a WAL location such as 0/3000060
+	LSN string
+}
+
+var lsnPattern = regexp.MustCompile(`^[0-9A-Fa-f]{1,8}/[0-9A-Fa-f]{1,8}$`)
+
+// restoreTimeLayouts are the timestamp formats accepted for --target-time
+var restoreTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// recoveryTargetTime checks a target time and formats it for
+// recovery_target_time. Times without an offset are left for the server to
+// interpret in its own time zone.
+func recoveryTargetTime(value string) (string, error) {
+	for i, layout := range restoreTimeLayouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		if i == len(restoreTimeLayouts)-1 {
+			return t.Format("2006-01-02 15:04:05.999999"), nil
+		}
+		return t.Format("2006-01-02 15:04:05.999999-07:00"), nil
+	}
+	return "", fmt.Errorf("invalid target time '%s': use a timestamp such as 2024-05-01T14:30:00Z or '2024-05-01 14:30:00'", value)
+}
+
+// RestorePlan renders restore.sh: a point-in-time recovery of one node from
+// the latest base backup in the backup repository to target
+func (g *Generator) RestorePlan(target RestoreTarget) (File, error) {
+	if !g.config.BackupEnabled() {
+		return File{}, fmt.Errorf("a restore plan needs backups; set backup.tool to local, pgbackrest or wal-g")
+	}
+
+	data := map[string]interface{}{
+		"Cluster":        g.config.Cluster,
+		"Primary":        g.config.Primary,
+		"Backup":         g.config.Backup,
+		"ConfigPath":     g.backupConfigPath(),
+		"RestoreCommand": g.restoreCommand(),
+		"LogDirectory":   g.config.LogDirectory(),
+	}
+	switch {
+	case target.Time != "" && target.LSN != "":
+		return File{}, fmt.Errorf("a restore plan recovers to a time or an LSN, not both")
+	case target.Time != "":
+		recoveryTime, err := recoveryTargetTime(target.Time)
+		if err != nil {
+			return File{}, err
+		}
+		data["TargetSetting"], data["TargetValue"] = "recovery_target_time", recoveryTime
+	case target.LSN != "":
+		if !lsnPattern.MatchString(target.LSN) {
+			return File{}, fmt.Errorf("invalid target LSN '%s': use a WAL location such as 0/3000060", target.LSN)
+		}
+		data["TargetSetting"], data["TargetValue"] = "recovery_target_lsn", target.LSN
+	default:
+		return File{}, fmt.Errorf("a restore plan needs a target time or LSN")
+	}
+
+	if target.Node == "" || target.Node == "primary" {
+		data["Node"], data["Host"], data["Port"] = "primary", g.config.Primary.Host, g.config.Primary.Port
+		// Replicas would promote themselves while the primary is down
+		data["Replicas"], data["ReplicaHealthUnit"] = g.config.Replicas, g.config.HealthUnitName()
+		data["TargetAction"] = "promote"
+	} else {
+		// The primary is still running, so a promoted replica would be a second
+		// writable node that the role check and Keepalived treat as primary
+		data["TargetAction"] = "pause"
+		found := false
+		for _, replica := range g.config.Replicas {
+			if replica.Host == target.Node {
+				data["Node"], data["Host"], data["Port"] = replicaDirName(replica.Host), replica.Host, replica.Port
+				data["HealthUnit"] = g.config.HealthUnitName()
+				found = true
+			}
+		}
+		if !found {
+			return File{}, fmt.Errorf("node '%s' is not the primary or a configured replica", target.Node)
+		}
+	}
+
+	tmpl, err := parseTemplateByName("restore.sh.tmpl")
+	if err != nil {
+		return File{}, err
+	}
+	var content bytes.Buffer
+	if err := tmpl.Execute(&content, data); err != nil {
+		return File{}, fmt.Errorf("failed to execute restore.sh template: %v", err)
+	}
+	return File{Path: "restore.sh", Mode: 0755, Content: content.Bytes(), Template: tmpl.Name()}, nil
+}
@@ -0,0 +1,232 @@
+package generator
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// File is a generated file held in memory until it is written
+type File struct {
+	// Path is relative to the output root, with forward slashes
+	Path     string
+	Mode     os.FileMode
+	Content  []byte
+	Template string
+}
+
+// Sink receives the files produced by GenerateAll
+type Sink interface {
+	// WriteFile stores one generated file
+	WriteFile(file File) error
+	// Close is called once after the last file has been written
+	Close() error
+}
+
+// configRecorder is implemented by sinks that record which config their files
+// were generated from
+type configRecorder interface {
+	recordConfig(hash string)
+}
+
+// DirSink writes files to a directory on disk. It keeps a manifest of what it
+// wrote, refuses to overwrite files edited since the last build unless Force is
+// set, and removes files for replicas that are no longer configured.
+type DirSink struct {
+	Dir   string
+	Force bool
+
+	files      []File
+	configHash string
+}
+
+// NewDirSink creates a DirSink writing to dir
+func NewDirSink(dir string) *DirSink {
+	return &DirSink{Dir: dir}
+}
+
+func (s *DirSink) recordConfig(hash string) {
+	s.configHash = hash
+}
+
+// WriteFile buffers the file; nothing is written until Close, so a refused
+// build leaves the directory untouched
+func (s *DirSink) WriteFile(file File) error {
+	s.files = append(s.files, file)
+	return nil
+}
+
+func (s *DirSink) Close() error {
+	plan, err := PlanWrite(s.Dir, s.files)
+	if err != nil {
+		return err
+	}
+	plan.configHash = s.configHash
+	return plan.Apply(s.Force)
+}
+
+// MemorySink keeps generated files in memory, keyed by path
+type MemorySink struct {
+	Files map[string]File
+}
+
+// NewMemorySink creates an empty MemorySink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{Files: map[string]File{}}
+}
+
+func (s *MemorySink) WriteFile(file File) error {
+	s.Files[file.Path] = file
+	return nil
+}
+
+func (s *MemorySink) Close() error {
+	return nil
+}
+
+// Paths returns the paths of all files in sorted order
+func (s *MemorySink) Paths() []string {
+	paths := make([]string, 0, len(s.Files))
+	for p := range s.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// TarSink streams files into a gzip-compressed tar archive. Closing the sink
+// finishes the archive but does not close the underlying writer.
+type TarSink struct {
+	gzip    *gzip.Writer
+	tar     *tar.Writer
+	modTime time.Time
+	dirs    map[string]bool
+}
+
+// NewTarSink creates a TarSink writing a .tar.gz stream to w
+func NewTarSink(w io.Writer) *TarSink {
+	gz := gzip.NewWriter(w)
+	return &TarSink{gzip: gz, tar: tar.NewWriter(gz), modTime: time.Now(), dirs: map[string]bool{}}
+}
+
+func (s *TarSink) WriteFile(file File) error {
+	// Add parent directories first so they extract with sensible permissions
+	if err := s.writeDir(path.Dir(file.Path)); err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name:    file.Path,
+		Mode:    int64(file.Mode.Perm()),
+		Size:    int64(len(file.Content)),
+		ModTime: s.modTime,
+	}
+	if err := s.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := s.tar.Write(file.Content)
+	return err
+}
+
+func (s *TarSink) writeDir(dir string) error {
+	if dir == "." || s.dirs[dir] {
+		return nil
+	}
+	if err := s.writeDir(path.Dir(dir)); err != nil {
+		return err
+	}
+	s.dirs[dir] = true
+	return s.tar.WriteHeader(&tar.Header{Name: dir + "/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: s.modTime})
+}
+
+func (s *TarSink) Close() error {
+	if err := s.tar.Close(); err != nil {
+		return err
+	}
+	return s.gzip.Close()
+}
+
+// ZipSink streams files into a zip archive. Closing the sink finishes the
+// archive but does not close the underlying writer.
+type ZipSink struct {
+	zip     *zip.Writer
+	modTime time.Time
+}
+
+// NewZipSink creates a ZipSink writing to w
+func NewZipSink(w io.Writer) *ZipSink {
+	return &ZipSink{zip: zip.NewWriter(w), modTime: time.Now()}
+}
+
+func (s *ZipSink) WriteFile(file File) error {
+	header := &zip.FileHeader{Name: file.Path, Method: zip.Deflate, Modified: s.modTime}
+	header.SetMode(file.Mode)
+	w, err := s.zip.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(file.Content)
+	return err
+}
+
+func (s *ZipSink) Close() error {
+	return s.zip.Close()
+}
+
+// SharSink writes a self-extracting shell archive. Running the archive
+// extracts the files into a temporary directory and, if Run is set, runs that
+// file from the archive with the archive's arguments before cleaning up.
+type SharSink struct {
+	Run string
+
+	w      io.Writer
+	err    error
+	header bool
+}
+
+// NewSharSink creates a SharSink writing to w
+func NewSharSink(w io.Writer) *SharSink {
+	return &SharSink{w: w}
+}
+
+func (s *SharSink) printf(format string, args ...any) {
+	if s.err == nil {
+		_, s.err = fmt.Fprintf(s.w, format, args...)
+	}
+}
+
+func (s *SharSink) writeHeader() {
+	if s.header {
+		return
+	}
+	s.header = true
+	s.printf("#!/bin/sh\n# Self-extracting archive generated by ha-syncgen\n\nset -e\n\n")
+	s.printf("ARCHIVE_DIR=\"$(mktemp -d)\"\ntrap 'rm -rf \"$ARCHIVE_DIR\"' EXIT\ncd \"$ARCHIVE_DIR\"\n")
+}
+
+func (s *SharSink) WriteFile(file File) error {
+	s.writeHeader()
+	name := ShellQuote(file.Path)
+	s.printf("\nmkdir -p %s\nbase64 -d > %s <<'SYNCGEN_EOF'\n", ShellQuote(path.Dir(file.Path)), name)
+	encoded := base64.StdEncoding.EncodeToString(file.Content)
+	for len(encoded) > 76 {
+		s.printf("%s\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	s.printf("%s\nSYNCGEN_EOF\nchmod %o %s\n", encoded, file.Mode.Perm(), name)
+	return s.err
+}
+
+func (s *SharSink) Close() error {
+	s.writeHeader()
+	if s.Run != "" {
+		s.printf("\n./%s \"$@\"\n", ShellQuote(s.Run))
+	}
+	return s.err
+}
@@ -0,0 +1,254 @@
+package generator
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testClusterYAML = `
+primary:
+  host: 10.0.0.1
+  port: 5432
+  data_directory: /var/lib/postgresql/data
+  db_name: postgres
+  db_user: postgres
+  db_password: secret
+  replication_user: replicator
+  replication_password: secret
+replicas:
+  - host: 10.0.0.2
+    port: 5432
+    replication_slot: replica_1
+    sync_mode: async
+`
+
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	doc, err := config.LoadBytes("cluster.yaml", []byte(testClusterYAML))
+	if err != nil {
+		t.Fatalf("LoadBytes() error = %v", err)
+	}
+	cfg, err := doc.Cluster("")
+	if err != nil {
+		t.Fatalf("Cluster() error = %v", err)
+	}
+	return cfg
+}
+
+func TestGenerateToMemory(t *testing.T) {
+	sink := NewMemorySink()
+	// Run from a different directory to check templates do not depend on the source tree
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := New(testConfig(t), sink).GenerateAll(); err != nil {
+		t.Fatalf("GenerateAll() error = %v", err)
+	}
+
+	want := []string{
+		"primary/pg_hba.conf.custom",
+		"primary/postgresql.conf.custom",
+		"primary/reconcile_slots.sh",
+		"primary/setup_primary.sh",
+		"replica-10.0.0.2/ha-postgres-health.service",
+		"replica-10.0.0.2/ha-postgres-health.timer",
+		"replica-10.0.0.2/health_check.sh",
+		"replica-10.0.0.2/pgpass",
+		"replica-10.0.0.2/setup_replication.sh",
+	}
+	if got := sink.Paths(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Paths() = %v, want %v", got, want)
+	}
+	if entries, _ := os.ReadDir("."); len(entries) != 0 {
+		t.Errorf("GenerateAll() to memory wrote %d entries to disk", len(entries))
+	}
+
+	script := sink.Files["replica-10.0.0.2/health_check.sh"]
+	if script.Mode != 0755 || script.Template != "health_check.sh.tmpl" || len(script.Content) == 0 {
+		t.Errorf("health_check.sh = mode %v, template %q, %d bytes", script.Mode, script.Template, len(script.Content))
+	}
+	service := sink.Files["replica-10.0.0.2/ha-postgres-health.service"]
+	if service.Mode != 0644 || !bytes.Contains(service.Content, []byte("ExecStart=/opt/ha-syncgen/replica-10.0.0.2/health_check.sh")) {
+		t.Errorf("service unit = mode %v:\n%s", service.Mode, service.Content)
+	}
+}
+
+func TestGenerateToDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "generated")
+	if err := New(testConfig(t), NewDirSink(dir)).GenerateAll(); err != nil {
+		t.Fatalf("GenerateAll() error = %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "replica-10.0.0.2", "setup_replication.sh"))
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Fatalf("setup_replication.sh = %v, %v; want mode 0755", info, err)
+	}
+	manifest, err := ReadManifest(dir)
+	if err != nil || len(manifest.Files) != 9 || manifest.ConfigHash == "" {
+		t.Errorf("manifest = %+v, %v", manifest, err)
+	}
+
+	plan, err := New(testConfig(t), nil).Plan(dir)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if plan.Drifted() {
+		t.Errorf("Plan() after GenerateAll() = %+v, want no changes", plan.Changes)
+	}
+}
+
+var archiveFiles = []File{
+	{Path: "primary/setup_primary.sh", Mode: 0755, Content: []byte("#!/bin/bash\n")},
+	{Path: "primary/pg_hba.conf.custom", Mode: 0644, Content: []byte("host all all 10.0.0.2/32 md5\n")},
+}
+
+func writeArchive(t *testing.T, sink Sink) {
+	t.Helper()
+	for _, file := range archiveFiles {
+		if err := sink.WriteFile(file); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestTarSink(t *testing.T) {
+	var buf bytes.Buffer
+	writeArchive(t, NewTarSink(&buf))
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := tar.NewReader(gz)
+	got := map[string]*tar.Header{}
+	contents := map[string]string{}
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[header.Name] = header
+		data, _ := io.ReadAll(reader)
+		contents[header.Name] = string(data)
+	}
+	if header := got["primary/"]; header == nil || header.Typeflag != tar.TypeDir {
+		t.Error("archive has no primary/ directory entry")
+	}
+	for _, file := range archiveFiles {
+		header := got[file.Path]
+		if header == nil || os.FileMode(header.Mode) != file.Mode || contents[file.Path] != string(file.Content) {
+			t.Errorf("%s = %+v %q", file.Path, header, contents[file.Path])
+		}
+	}
+}
+
+func TestZipSink(t *testing.T) {
+	var buf bytes.Buffer
+	writeArchive(t, NewZipSink(&buf))
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reader.File) != len(archiveFiles) {
+		t.Fatalf("archive has %d files, want %d", len(reader.File), len(archiveFiles))
+	}
+	for i, f := range reader.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		want := archiveFiles[i]
+		if f.Name != want.Path || f.Mode().Perm() != want.Mode || string(data) != string(want.Content) {
+			t.Errorf("entry %d = %s %v %q, want %s %v", i, f.Name, f.Mode(), data, want.Path, want.Mode)
+		}
+	}
+}
+
+func TestSharSink(t *testing.T) {
+	if _, err := exec.LookPath("base64"); err != nil {
+		t.Skip("base64 not installed")
+	}
+	var buf bytes.Buffer
+	sink := NewSharSink(&buf)
+	sink.Run = "primary/check.sh"
+	// check.sh copies the extracted files out before the archive cleans up
+	files := append([]File{{Path: "primary/check.sh", Mode: 0755, Content: []byte("#!/bin/sh\ncp -Rp \"$(dirname \"$0\")\" \"$1\"\n")}}, archiveFiles...)
+	for _, file := range files {
+		if err := sink.WriteFile(file); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "primary.shar")
+	if err := os.WriteFile(archive, buf.Bytes(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "extracted")
+	if output, err := exec.Command("sh", archive, out).CombinedOutput(); err != nil {
+		t.Fatalf("running archive: %v\n%s", err, output)
+	}
+	for _, file := range archiveFiles {
+		extracted := filepath.Join(out, filepath.Base(file.Path))
+		info, err := os.Stat(extracted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(extracted)
+		if info.Mode().Perm() != file.Mode || string(data) != string(file.Content) {
+			t.Errorf("%s = %v %q, want %v %q", file.Path, info.Mode(), data, file.Mode, file.Content)
+		}
+	}
+}
+
+func TestBundles(t *testing.T) {
+	bundles, err := New(testConfig(t), nil).Bundles()
+	if err != nil {
+		t.Fatalf("Bundles() error = %v", err)
+	}
+	if len(bundles) != 2 || bundles[0].Node != "primary" || bundles[1].Node != "replica-10.0.0.2" {
+		t.Fatalf("Bundles() = %+v", bundles)
+	}
+
+	replica := map[string]File{}
+	for _, file := range bundles[1].Files {
+		replica[file.Path] = file
+	}
+	install, ok := replica["install.sh"]
+	if !ok || install.Mode != 0755 || !bytes.Contains(install.Content, []byte(`INSTALL_DIR="/opt/ha-syncgen/replica-10.0.0.2"`)) {
+		t.Errorf("install.sh = %+v", install)
+	}
+	if pgpass := replica["pgpass"]; pgpass.Mode != 0600 || !bytes.Contains(pgpass.Content, []byte("10.0.0.1:5432:*:replicator:secret")) {
+		t.Errorf("pgpass = mode %v:\n%s", pgpass.Mode, pgpass.Content)
+	}
+	for _, file := range bundles[0].Files {
+		if strings.Contains(file.Path, "replica") || file.Path == "pgpass" {
+			t.Errorf("primary bundle contains %s", file.Path)
+		}
+	}
+}
@@ -1,24 +1,42 @@
 package generator
 
 import (
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
 	"path/filepath"
-	"syncgen/internal/config"
 )
 
 // generateSyncScript creates the streaming replication setup script using a Go template
 func (g *Generator) generateSyncScript(replica config.Replica, replicaDir string) error {
-	tmplDir, tmplErr := getTemplateDirectory()
-	if tmplErr != nil {
-		return tmplErr
-	}
-	syncScriptTmpl, err := parseTemplateByName(tmplDir, "setup_replication.sh.tmpl")
+	syncScriptTmpl, err := parseTemplateByName("setup_replication.sh.tmpl")
 	if err != nil {
 		return err
 	}
+	upstreamHost, upstreamPort := g.config.UpstreamAddress(replica)
 	data := map[string]interface{}{
-		"Replica": replica,
-		"Primary": g.config.Primary,
+		"Replica":         replica,
+		"Primary":         g.config.Primary,
+		"UpstreamHost":    upstreamHost,
+		"UpstreamPort":    upstreamPort,
+		"Downstreams":     g.config.Downstreams(replica.Host),
+		"ApplicationName": g.config.ApplicationName(replica),
+		"LogDirectory":    g.config.LogDirectory(),
+		"Backup":          g.backupSetup(),
 	}
 	outputFile := filepath.Join(replicaDir, "setup_replication.sh")
-	return executeTemplateToFile(syncScriptTmpl, data, outputFile, "setup_replication.sh")
+	return g.renderFile(syncScriptTmpl, data, outputFile, "setup_replication.sh")
+}
+
+// generateUpstreamHba creates the pg_hba.conf entries a replica needs to serve
+// the replicas that stream from it
+func (g *Generator) generateUpstreamHba(replica config.Replica, replicaDir string) error {
+	pgHbaTmpl, err := parseTemplateByName("pg_hba.conf.tmpl")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"ReplicationUser": g.config.Primary.ReplicationUser,
+		"Replicas":        g.config.Downstreams(replica.Host),
+	}
+	outputFile := filepath.Join(replicaDir, "pg_hba.conf.custom")
+	return g.renderFile(pgHbaTmpl, data, outputFile, "pg_hba.conf.custom")
 }
@@ -1,42 +1,43 @@
 package generator
 
 import (
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"path"
 	"path/filepath"
-	"syncgen/internal/config"
 )
 
 // generateSystemdService creates a systemd service unit for PostgreSQL replication management using a template
 func (g *Generator) generateSystemdService(replica config.Replica, replicaDir string) error {
-	tmplDir, tmplErr := getTemplateDirectory()
-	if tmplErr != nil {
-		return tmplErr
-	}
-	serviceTmpl, err := parseTemplateByName(tmplDir, "ha-postgres-health.service.tmpl")
+	serviceTmpl, err := parseTemplateByName("ha-postgres-health.service.tmpl")
 	if err != nil {
 		return err
 	}
 	data := map[string]interface{}{
-		"Replica":    replica,
-		"Primary":    g.config.Primary,
-		"ReplicaDir": replicaDir,
+		"Replica":      replica,
+		"Primary":      g.config.Primary,
+		"Cluster":      g.config.Cluster,
+		"ReplicaDir":   path.Join(g.config.InstallDirectory(), replicaDir),
+		"UnitName":     g.config.HealthUnitName(),
+		"LogDirectory": g.config.LogDirectory(),
+		"PgBouncer":    g.config.PgBouncerEnabled(),
 	}
-	outputFile := filepath.Join(replicaDir, "ha-postgres-health.service")
-	return executeTemplateToFile(serviceTmpl, data, outputFile, "ha-postgres-health.service")
+	filename := g.config.HealthUnitName() + ".service"
+	outputFile := filepath.Join(replicaDir, filename)
+	return g.renderFile(serviceTmpl, data, outputFile, filename)
 }
 
 // generateSystemdTimer creates a systemd timer unit for regular health checks using a template
 func (g *Generator) generateSystemdTimer(replica config.Replica, replicaDir string) error {
-	tmplDir, tmplErr := getTemplateDirectory()
-	if tmplErr != nil {
-		return tmplErr
-	}
-	timerTmpl, err := parseTemplateByName(tmplDir, "ha-postgres-health.timer.tmpl")
+	timerTmpl, err := parseTemplateByName("ha-postgres-health.timer.tmpl")
 	if err != nil {
 		return err
 	}
 	data := map[string]interface{}{
-		"Replica": replica,
+		"Replica":  replica,
+		"Cluster":  g.config.Cluster,
+		"UnitName": g.config.HealthUnitName(),
 	}
-	outputFile := filepath.Join(replicaDir, "ha-postgres-health.timer")
-	return executeTemplateToFile(timerTmpl, data, outputFile, "ha-postgres-health.timer")
+	filename := g.config.HealthUnitName() + ".timer"
+	outputFile := filepath.Join(replicaDir, filename)
+	return g.renderFile(timerTmpl, data, outputFile, filename)
 }
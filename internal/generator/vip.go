@@ -0,0 +1,48 @@
+package generator
+
+import (
+	"path"
+	"path/filepath"
+)
+
+// VRRP priorities: the primary starts out holding the VIP, and after a
+// failover the check script keeps it on whichever node was promoted
+const (
+	primaryVIPPriority = 150
+	replicaVIPPriority = 100
+)
+
+// generateVIPFiles creates a node's Keepalived configuration with the check
+// script that only passes on the writable primary and the notify script that
+// logs the node's VRRP state changes
+func (g *Generator) generateVIPFiles(nodeDir, host string, port, priority int) error {
+	data := map[string]interface{}{
+		"Node":         nodeDir,
+		"Host":         host,
+		"Port":         port,
+		"Priority":     priority,
+		"Cluster":      g.config.Cluster,
+		"VIP":          g.config.Routing.VIP,
+		"Instance":     g.config.VIPInstanceName(),
+		"NodeDir":      path.Join(g.config.InstallDirectory(), nodeDir),
+		"LogDirectory": g.config.LogDirectory(),
+	}
+	files := []struct {
+		template string
+		filename string
+	}{
+		{"keepalived.conf.tmpl", "keepalived.conf"},
+		{"vip_check.sh.tmpl", "vip_check.sh"},
+		{"vip_notify.sh.tmpl", "vip_notify.sh"},
+	}
+	for _, file := range files {
+		tmpl, err := parseTemplateByName(file.template)
+		if err != nil {
+			return err
+		}
+		if err := g.renderFile(tmpl, data, filepath.Join(nodeDir, file.filename), file.filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/version"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFile records every file written by the last build, so later builds
+// can tell generated files from local edits
+const ManifestFile = "manifest.json"
+
+// Manifest describes the output of a build
+type Manifest struct {
+	SyncgenVersion string          `json:"syncgen_version"`
+	ConfigHash     string          `json:"config_hash"`
+	Files          []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry is one generated file in a Manifest
+type ManifestEntry struct {
+	Path     string `json:"path"`
+	SHA256   string `json:"sha256"`
+	Template string `json:"template"`
+}
+
+// Action is what writing a plan does to one file
+type Action string
+
+const (
+	Create    Action = "create"
+	Update    Action = "update"
+	Unchanged Action = "unchanged"
+	Remove    Action = "remove"
+)
+
+// Change is one file in a Plan
+type Change struct {
+	Path   string
+	Action Action
+	Mode   os.FileMode
+	Size   int
+	// Modified is set when the file on disk was edited since it was generated
+	Modified bool
+}
+
+// Plan is the set of changes needed to bring an output directory in line with
+// freshly rendered files
+type Plan struct {
+	Dir     string
+	Changes []Change
+	// Previous is the manifest of the last build, empty if there was none
+	Previous   *Manifest
+	files      map[string]File
+	configHash string
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ReadManifest reads the manifest in an output directory. A directory that was
+// never built has an empty manifest.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Manifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", filepath.Join(dir, ManifestFile), err)
+	}
+	return m, nil
+}
+
+// hashes maps each path in the manifest to the sha256 of its content
+func (m *Manifest) hashes() map[string]string {
+	hashes := map[string]string{}
+	for _, entry := range m.Files {
+		hashes[entry.Path] = entry.SHA256
+	}
+	return hashes
+}
+
+// diskHash returns the hash of a file on disk, or "" if it does not exist
+func diskHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return hashContent(data), nil
+}
+
+// PlanWrite compares rendered files against the output directory. Files listed
+// in the manifest but no longer rendered, and anything left in replica-<host>
+// directories for replicas removed from the config, are planned for removal.
+func PlanWrite(dir string, files []File) (*Plan, error) {
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	previous := manifest.hashes()
+
+	plan := &Plan{Dir: dir, Previous: manifest, files: map[string]File{}}
+	replicaDirs := map[string]bool{}
+	for _, file := range files {
+		plan.files[file.Path] = file
+		if top := strings.SplitN(file.Path, "/", 2)[0]; strings.HasPrefix(top, "replica-") {
+			replicaDirs[top] = true
+		}
+
+		current, err := diskHash(filepath.Join(dir, filepath.FromSlash(file.Path)))
+		if err != nil {
+			return nil, err
+		}
+		change := Change{Path: file.Path, Mode: file.Mode, Size: len(file.Content)}
+		switch {
+		case current == "":
+			change.Action = Create
+		case current == hashContent(file.Content):
+			change.Action = Unchanged
+		default:
+			change.Action = Update
+			// Files not in the manifest predate it, so any difference counts as a local edit
+			change.Modified = current != previous[file.Path]
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	stale := map[string]bool{}
+	for path := range previous {
+		if _, ok := plan.files[path]; !ok {
+			stale[path] = true
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "replica-") || replicaDirs[entry.Name()] {
+			continue
+		}
+		err := filepath.WalkDir(filepath.Join(dir, entry.Name()), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			stale[filepath.ToSlash(rel)] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for path := range stale {
+		current, err := diskHash(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			return nil, err
+		}
+		if current == "" {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Path: path, Action: Remove, Modified: current != previous[path]})
+	}
+
+	sort.SliceStable(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].Path < plan.Changes[j].Path
+	})
+	return plan, nil
+}
+
+// File returns the rendered file planned for path
+func (p *Plan) File(path string) (File, bool) {
+	file, ok := p.files[path]
+	return file, ok
+}
+
+// Drifted reports whether the output directory differs from the rendered files
+func (p *Plan) Drifted() bool {
+	for _, change := range p.Changes {
+		if change.Action != Unchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// Conflicts returns the changes that would overwrite or remove locally
+// modified files
+func (p *Plan) Conflicts() []Change {
+	var conflicts []Change
+	for _, change := range p.Changes {
+		if change.Modified {
+			conflicts = append(conflicts, change)
+		}
+	}
+	return conflicts
+}
+
+// Apply writes the plan to disk and records the new manifest. Unless force is
+// set, it writes nothing if any change conflicts with a local modification.
+func (p *Plan) Apply(force bool) error {
+	if conflicts := p.Conflicts(); len(conflicts) > 0 && !force {
+		paths := make([]string, len(conflicts))
+		for i, change := range conflicts {
+			paths[i] = filepath.Join(p.Dir, filepath.FromSlash(change.Path))
+		}
+		return fmt.Errorf("refusing to overwrite locally modified files (use --force to overwrite): %s", strings.Join(paths, ", "))
+	}
+
+	next := &Manifest{SyncgenVersion: version.String(), ConfigHash: p.configHash, Files: []ManifestEntry{}}
+	for _, change := range p.Changes {
+		path := filepath.Join(p.Dir, filepath.FromSlash(change.Path))
+		switch change.Action {
+		case Create, Update:
+			file := p.files[change.Path]
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, file.Content, file.Mode); err != nil {
+				return err
+			}
+			// WriteFile keeps the mode of existing files
+			if err := os.Chmod(path, file.Mode); err != nil {
+				return err
+			}
+			next.add(file)
+		case Unchanged:
+			file := p.files[change.Path]
+			if err := os.Chmod(path, file.Mode); err != nil {
+				return err
+			}
+			next.add(file)
+		case Remove:
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			removeEmptyParents(p.Dir, filepath.Dir(path))
+		}
+	}
+
+	data, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p.Dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(p.Dir, ManifestFile), append(data, '\n'), 0644)
+}
+
+func (m *Manifest) add(file File) {
+	m.Files = append(m.Files, ManifestEntry{Path: file.Path, SHA256: hashContent(file.Content), Template: file.Template})
+}
+
+// removeEmptyParents removes dir and its parents up to, but not including,
+// root while they are empty
+func removeEmptyParents(root, dir string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,148 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func findChange(t *testing.T, plan *Plan, path string) Change {
+	t.Helper()
+	for _, change := range plan.Changes {
+		if change.Path == path {
+			return change
+		}
+	}
+	t.Fatalf("no change for %s in %+v", path, plan.Changes)
+	return Change{}
+}
+
+func writeFiles(t *testing.T, dir string, files []File, force bool) {
+	t.Helper()
+	plan, err := PlanWrite(dir, files)
+	if err != nil {
+		t.Fatalf("PlanWrite() error = %v", err)
+	}
+	if err := plan.Apply(force); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+}
+
+func TestPlanWrite(t *testing.T) {
+	dir := t.TempDir()
+	files := []File{
+		{Path: "primary/setup_primary.sh", Mode: 0755, Content: []byte("#!/bin/bash\n"), Template: "setup_primary.sh.tmpl"},
+		{Path: "replica-10.0.0.2/health_check.sh", Mode: 0755, Content: []byte("#!/bin/bash\n")},
+		{Path: "replica-10.0.0.3/health_check.sh", Mode: 0755, Content: []byte("#!/bin/bash\n")},
+	}
+
+	plan, err := PlanWrite(dir, files)
+	if err != nil {
+		t.Fatalf("PlanWrite() error = %v", err)
+	}
+	for _, change := range plan.Changes {
+		if change.Action != Create || change.Size != 12 || change.Mode != 0755 {
+			t.Errorf("first build: %+v, want a 12 byte 0755 create", change)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "primary")); !os.IsNotExist(err) {
+		t.Error("PlanWrite() wrote to the output directory")
+	}
+	writeFiles(t, dir, files, false)
+
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatalf("ReadManifest() error = %v", err)
+	}
+	if len(manifest.Files) != 3 || manifest.Files[0].Template != "setup_primary.sh.tmpl" || manifest.Files[0].SHA256 != hashContent(files[0].Content) {
+		t.Errorf("manifest = %+v", manifest)
+	}
+	if manifest.SyncgenVersion == "" {
+		t.Error("manifest has no syncgen version")
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "primary", "setup_primary.sh"))
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Fatalf("setup_primary.sh = %v, %v; want mode 0755", info, err)
+	}
+
+	// Drop replica 10.0.0.3 and change the primary script
+	next := []File{
+		{Path: "primary/setup_primary.sh", Mode: 0755, Content: []byte("#!/bin/bash\nset -e\n")},
+		files[1],
+	}
+	plan, err = PlanWrite(dir, next)
+	if err != nil {
+		t.Fatalf("PlanWrite() error = %v", err)
+	}
+	if change := findChange(t, plan, "primary/setup_primary.sh"); change.Action != Update || change.Modified {
+		t.Errorf("changed template output = %+v, want an unmodified update", change)
+	}
+	if change := findChange(t, plan, "replica-10.0.0.2/health_check.sh"); change.Action != Unchanged {
+		t.Errorf("same output = %+v, want unchanged", change)
+	}
+	if change := findChange(t, plan, "replica-10.0.0.3/health_check.sh"); change.Action != Remove {
+		t.Errorf("removed replica = %+v, want remove", change)
+	}
+	if !plan.Drifted() {
+		t.Error("Drifted() = false with pending changes")
+	}
+	if len(plan.Conflicts()) != 0 {
+		t.Errorf("Conflicts() = %+v, want none", plan.Conflicts())
+	}
+	if err := plan.Apply(false); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "replica-10.0.0.3")); !os.IsNotExist(err) {
+		t.Error("stale replica-10.0.0.3 directory was not removed")
+	}
+}
+
+func TestPlanWriteLocalModifications(t *testing.T) {
+	dir := t.TempDir()
+	files := []File{{Path: "replica-10.0.0.2/health_check.sh", Mode: 0755, Content: []byte("#!/bin/bash\n")}}
+	writeFiles(t, dir, files, false)
+
+	edited := filepath.Join(dir, "replica-10.0.0.2", "health_check.sh")
+	if err := os.WriteFile(edited, []byte("#!/bin/bash\n# local edit\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A leftover directory from a replica that was never in the manifest
+	untracked := filepath.Join(dir, "replica-10.0.0.9", "notes.txt")
+	if err := os.MkdirAll(filepath.Dir(untracked), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(untracked, []byte("notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := PlanWrite(dir, files)
+	if err != nil {
+		t.Fatalf("PlanWrite() error = %v", err)
+	}
+	if change := findChange(t, plan, "replica-10.0.0.2/health_check.sh"); change.Action != Update || !change.Modified {
+		t.Errorf("edited file = %+v, want a modified update", change)
+	}
+	if change := findChange(t, plan, "replica-10.0.0.9/notes.txt"); change.Action != Remove || !change.Modified {
+		t.Errorf("untracked file = %+v, want a modified remove", change)
+	}
+
+	err = plan.Apply(false)
+	if err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("Apply(false) error = %v, want a refusal mentioning --force", err)
+	}
+	if data, _ := os.ReadFile(edited); !strings.Contains(string(data), "local edit") {
+		t.Error("Apply(false) overwrote a locally modified file")
+	}
+
+	if err := plan.Apply(true); err != nil {
+		t.Fatalf("Apply(true) error = %v", err)
+	}
+	if data, _ := os.ReadFile(edited); string(data) != "#!/bin/bash\n" {
+		t.Errorf("Apply(true) left %q", data)
+	}
+	if _, err := os.Stat(untracked); !os.IsNotExist(err) {
+		t.Error("Apply(true) did not remove the stale replica file")
+	}
+}
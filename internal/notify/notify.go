@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Event describes a failover event delivered to notification sinks
+type Event struct {
+	Name      string
+	Message   string
+	Node      string
+	Primary   string
+	Cluster   string
+	Timestamp time.Time
+}
+
+// Notifier delivers events to the sinks configured in the notifications section.
+// It mirrors the behaviour of the generated notify.sh so that `syncgen notify test`
+// exercises the same payloads, event filters and retries.
+type Notifier struct {
+	notifications *config.Notifications
+	retryDelay    time.Duration
+	client        *http.Client
+	sendMail      func(addr string, a smtp.Auth, from string, to []string, msg []byte, startTLS bool) error
+}
+
+// New creates a Notifier for a validated notifications section
+func New(notifications *config.Notifications) (*Notifier, error) {
+	retryDelay, err := time.ParseDuration(notifications.RetryDelay)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retry_delay: %w", err)
+	}
+	return &Notifier{
+		notifications: notifications,
+		retryDelay:    retryDelay,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		sendMail:      sendMail,
+	}, nil
+}
+
+// Send delivers the event to every sink subscribed to it. If sinkName is not
+// empty only that sink is used, regardless of its event filter.
+func (n *Notifier) Send(event Event, sinkName string) error {
+	var errs []error
+	matched := false
+	for _, sink := range n.notifications.Sinks {
+		if sinkName != "" && sink.Name != sinkName {
+			continue
+		}
+		if sinkName == "" && !sink.Accepts(event.Name) {
+			continue
+		}
+		matched = true
+		if err := n.withRetries(func() error { return n.deliver(sink, event) }); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name, err))
+		}
+	}
+	if sinkName != "" && !matched {
+		return fmt.Errorf("no notification sink named '%s'", sinkName)
+	}
+	return errors.Join(errs...)
+}
+
+// withRetries runs fn until it succeeds or the configured retry budget is exhausted
+func (n *Notifier) withRetries(fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= n.notifications.Retries; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt < n.notifications.Retries {
+			time.Sleep(n.retryDelay)
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", n.notifications.Retries, err)
+}
+
+func (n *Notifier) deliver(sink config.NotificationSink, event Event) error {
+	switch sink.Type {
+	case "webhook":
+		return n.post(sink.URL, sink.Headers, RenderPayload(sink.Body, event))
+	case "slack", "teams":
+		text := fmt.Sprintf("%s %s on %s: %s", event.prefix(), event.Name, event.Node, event.Message)
+		payload, err := json.Marshal(map[string]string{"text": text})
+		if err != nil {
+			return err
+		}
+		return n.post(sink.URL, nil, string(payload))
+	case "email":
+		return n.mail(sink.SMTP, event)
+	default:
+		return fmt.Errorf("unsupported sink type '%s'", sink.Type)
+	}
+}
+
+func (n *Notifier) post(url string, headers map[string]string, payload string) error {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
+
+func (n *Notifier) mail(smtpCfg *config.SMTPConfig, event Event) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", smtpCfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(smtpCfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s %s on %s\r\n", event.prefix(), event.Name, event.Node)
+	fmt.Fprintf(&msg, "Date: %s\r\n\r\n", event.Timestamp.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "%s\r\n\r\nPrimary: %s\r\nTime: %s\r\n", event.Message, event.Primary, formatTimestamp(event.Timestamp))
+
+	var auth smtp.Auth
+	if smtpCfg.Username != "" {
+		auth = smtp.PlainAuth("", smtpCfg.Username, smtpCfg.Password, smtpCfg.Host)
+	}
+	addr := fmt.Sprintf("%s:%d", smtpCfg.Host, smtpCfg.Port)
+	return n.sendMail(addr, auth, smtpCfg.From, smtpCfg.To, msg.Bytes(), smtpCfg.StartTLS)
+}
+
+// sendMail delivers msg like smtp.SendMail, except that the connection is only
+// upgraded when startTLS is set and then fails if the server does not offer
+// STARTTLS, matching curl's --ssl-reqd in the generated notify.sh
+func sendMail(addr string, a smtp.Auth, from string, to []string, msg []byte, startTLS bool) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if startTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS", addr)
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if a != nil {
+		if err := c.Auth(a); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// RenderPayload substitutes the event placeholders in a webhook body template.
+// Values are JSON-escaped so they can be embedded inside JSON strings.
+func RenderPayload(body string, event Event) string {
+	replacer := strings.NewReplacer(
+		"${EVENT}", jsonEscape(event.Name),
+		"${MESSAGE}", jsonEscape(event.Message),
+		"${NODE}", jsonEscape(event.Node),
+		"${PRIMARY}", jsonEscape(event.Primary),
+		"${CLUSTER}", jsonEscape(event.Cluster),
+		"${TIMESTAMP}", jsonEscape(formatTimestamp(event.Timestamp)),
+	)
+	return replacer.Replace(body)
+}
+
+// prefix tags chat and email messages with the tool and cluster name
+func (e Event) prefix() string {
+	if e.Cluster == "" {
+		return "[ha-syncgen]"
+	}
+	return fmt.Sprintf("[ha-syncgen %s]", e.Cluster)
+}
+
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+func jsonEscape(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded[1 : len(encoded)-1])
+}
@@ -0,0 +1,243 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testEvent(name string) Event {
+	return Event{
+		Name:      name,
+		Message:   `primary "10.0.0.1" is down`,
+		Node:      "10.0.0.2",
+		Primary:   "10.0.0.1",
+		Cluster:   "orders",
+		Timestamp: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestRenderPayload(t *testing.T) {
+	got := RenderPayload(config.DefaultWebhookBody, testEvent("primary_down"))
+
+	var decoded map[string]string
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatalf("rendered payload is not valid JSON: %v\n%s", err, got)
+	}
+	want := map[string]string{
+		"event":     "primary_down",
+		"message":   `primary "10.0.0.1" is down`,
+		"node":      "10.0.0.2",
+		"primary":   "10.0.0.1",
+		"cluster":   "orders",
+		"timestamp": "2025-01-02T03:04:05Z",
+	}
+	for key, value := range want {
+		if decoded[key] != value {
+			t.Errorf("payload[%q] = %q, want %q", key, decoded[key], value)
+		}
+	}
+}
+
+func TestSendWebhookRetriesAndFilters(t *testing.T) {
+	var calls atomic.Int32
+	var lastBody, lastHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lastBody = string(body)
+		lastHeader = r.Header.Get("X-Token")
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	notifier, err := New(&config.Notifications{
+		Retries:    3,
+		RetryDelay: "1ms",
+		Sinks: []config.NotificationSink{
+			{
+				Name:    "hook",
+				Type:    "webhook",
+				URL:     server.URL,
+				Headers: map[string]string{"X-Token": "secret"},
+				Body:    `{"kind":"${EVENT}"}`,
+				Events:  []string{"promoted"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	if err := notifier.Send(testEvent("warning"), ""); err != nil {
+		t.Fatalf("Send() for filtered event failed: %v", err)
+	}
+	if calls.Load() != 0 {
+		t.Fatalf("filtered event reached the sink %d times", calls.Load())
+	}
+
+	if err := notifier.Send(testEvent("promoted"), ""); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 attempts (one retry), got %d", calls.Load())
+	}
+	if lastBody != `{"kind":"promoted"}` {
+		t.Errorf("body = %q", lastBody)
+	}
+	if lastHeader != "secret" {
+		t.Errorf("X-Token header = %q, want %q", lastHeader, "secret")
+	}
+}
+
+func TestSendSlackAndEmail(t *testing.T) {
+	var slackBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		slackBody = string(body)
+	}))
+	defer server.Close()
+
+	notifier, err := New(&config.Notifications{
+		Retries:    1,
+		RetryDelay: "1ms",
+		Sinks: []config.NotificationSink{
+			{Name: "chat", Type: "slack", URL: server.URL},
+			{Name: "mail", Type: "email", SMTP: &config.SMTPConfig{
+				Host: "smtp.example.com", Port: 587, From: "ha@example.com", To: []string{"ops@example.com"}, StartTLS: true,
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	var mailAddr string
+	var mailMsg []byte
+	var mailStartTLS bool
+	notifier.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte, startTLS bool) error {
+		mailAddr = addr
+		mailMsg = msg
+		mailStartTLS = startTLS
+		return nil
+	}
+
+	if err := notifier.Send(testEvent("promoted"), ""); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	if !strings.Contains(slackBody, `"text":"[ha-syncgen orders] promoted on 10.0.0.2`) {
+		t.Errorf("unexpected Slack payload: %s", slackBody)
+	}
+	if mailAddr != "smtp.example.com:587" || !mailStartTLS {
+		t.Errorf("mail address = %q, starttls = %v", mailAddr, mailStartTLS)
+	}
+	if !strings.Contains(string(mailMsg), "Subject: [ha-syncgen orders] promoted on 10.0.0.2") {
+		t.Errorf("unexpected mail message: %s", mailMsg)
+	}
+}
+
+// fakeSMTPServer accepts a single connection, advertises the given EHLO
+// extensions and reports the commands the client sent once it disconnects
+func fakeSMTPServer(t *testing.T, extensions ...string) (string, <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	commands := make(chan []string, 1)
+	go func() {
+		var got []string
+		defer func() { commands <- got }()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 fake ESMTP\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			verb, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+			verb = strings.ToUpper(verb)
+			got = append(got, verb)
+			switch verb {
+			case "EHLO":
+				lines := append([]string{"fake"}, extensions...)
+				for i, ext := range lines {
+					sep := "-"
+					if i == len(lines)-1 {
+						sep = " "
+					}
+					fmt.Fprintf(conn, "250%s%s\r\n", sep, ext)
+				}
+			case "STARTTLS":
+				fmt.Fprint(conn, "454 TLS not available\r\n")
+			case "DATA":
+				fmt.Fprint(conn, "354 go ahead\r\n")
+				for {
+					if line, err = r.ReadString('\n'); err != nil || line == ".\r\n" {
+						break
+					}
+				}
+				fmt.Fprint(conn, "250 queued\r\n")
+			case "QUIT":
+				fmt.Fprint(conn, "221 bye\r\n")
+				return
+			default:
+				fmt.Fprint(conn, "250 ok\r\n")
+			}
+		}
+	}()
+	return ln.Addr().String(), commands
+}
+
+func TestSendMailStartTLS(t *testing.T) {
+	msg := []byte("Subject: test\r\n\r\nbody\r\n")
+	to := []string{"ops@example.com"}
+
+	addr, commands := fakeSMTPServer(t)
+	err := sendMail(addr, nil, "ha@example.com", to, msg, true)
+	if err == nil || !strings.Contains(err.Error(), "does not support STARTTLS") {
+		t.Errorf("sendMail() with starttls against a plain server error = %v", err)
+	}
+	if got := <-commands; slices.Contains(got, "DATA") {
+		t.Errorf("sendMail() delivered without STARTTLS: %v", got)
+	}
+
+	addr, commands = fakeSMTPServer(t, "STARTTLS")
+	if err := sendMail(addr, nil, "ha@example.com", to, msg, false); err != nil {
+		t.Fatalf("sendMail() without starttls failed: %v", err)
+	}
+	if got := <-commands; slices.Contains(got, "STARTTLS") || !slices.Contains(got, "DATA") {
+		t.Errorf("sendMail() without starttls sent %v", got)
+	}
+}
+
+func TestSendUnknownSink(t *testing.T) {
+	notifier, err := New(&config.Notifications{Retries: 1, RetryDelay: "1s"})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := notifier.Send(testEvent("promoted"), "missing"); err == nil {
+		t.Error("expected an error for an unknown sink")
+	}
+}
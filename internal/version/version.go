@@ -0,0 +1,21 @@
+// Package version reports which build of syncgen is running
+package version
+
+import "runtime/debug"
+
+// Version is set at release time with
+//
+//	go build -ldflags "-X syncgen/internal/version.Version=v1.2.3"
+var Version = ""
+
+// String returns the release version, falling back to the module version
+// recorded by go install, or "dev" for local builds
+func String() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
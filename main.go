@@ -1,6 +1,6 @@
 package main
 
-import "syncgen/cmd"
+import "github.com/HasithDeAlwis/ha-syncgen/cmd"
 
 func main() {
 	cmd.Execute()
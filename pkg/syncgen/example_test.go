@@ -0,0 +1,83 @@
+package syncgen_test
+
+import (
+	"fmt"
+	"github.com/HasithDeAlwis/ha-syncgen/pkg/syncgen"
+	"log"
+	"os"
+)
+
+const exampleYAML = `
+primary:
+  host: 10.0.0.1
+  port: 5432
+  data_directory: /var/lib/postgresql/data
+  db_name: postgres
+  db_user: postgres
+  db_password: secret
+  replication_user: replicator
+  replication_password: secret
+replicas:
+  - host: 10.0.0.2
+    port: 5432
+    replication_slot: replica_1
+    sync_mode: async
+`
+
+func Example() {
+	doc, err := syncgen.LoadBytes("cluster.yaml", []byte(exampleYAML))
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg, err := doc.Cluster("")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sink := syncgen.NewMemorySink()
+	if err := syncgen.Generate(cfg, sink); err != nil {
+		log.Fatal(err)
+	}
+	for _, path := range sink.Paths() {
+		fmt.Printf("%v %s\n", sink.Files[path].Mode, path)
+	}
+	// Output:
+	// -rw-r--r-- primary/pg_hba.conf.custom
+	// -rw-r--r-- primary/postgresql.conf.custom
+	// -rwxr-xr-x primary/reconcile_slots.sh
+	// -rwxr-xr-x primary/setup_primary.sh
+	// -rw-r--r-- replica-10.0.0.2/ha-postgres-health.service
+	// -rw-r--r-- replica-10.0.0.2/ha-postgres-health.timer
+	// -rwxr-xr-x replica-10.0.0.2/health_check.sh
+	// -rw------- replica-10.0.0.2/pgpass
+	// -rwxr-xr-x replica-10.0.0.2/setup_replication.sh
+}
+
+func ExamplePlanDir() {
+	doc, err := syncgen.LoadBytes("cluster.yaml", []byte(exampleYAML))
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg, err := doc.Cluster("")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dir, err := os.MkdirTemp("", "syncgen")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	plan, err := syncgen.PlanDir(cfg, dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	counts := map[syncgen.Action]int{}
+	for _, change := range plan.Changes {
+		counts[change.Action]++
+	}
+	fmt.Println(counts[syncgen.Create], "to create,", counts[syncgen.Unchanged], "unchanged")
+	// Output:
+	// 9 to create, 0 unchanged
+}
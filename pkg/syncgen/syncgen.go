@@ -0,0 +1,124 @@
+// Package syncgen is the Go API for ha-syncgen. It parses and validates
+// cluster configuration files and renders the HA scripts and configuration
+// for them into a Sink: a directory, memory, or a tar or zip stream.
+//
+//	doc, err := syncgen.Load("cluster.yaml")
+//	if err != nil {
+//		return err
+//	}
+//	cfg, err := doc.Cluster("")
+//	if err != nil {
+//		return err
+//	}
+//	files, err := syncgen.Render(cfg)
+//
+// The types below are aliases for the ones the syncgen command uses, so values
+// can be passed between this package and the rest of the API freely.
+package syncgen
+
+import (
+	"github.com/HasithDeAlwis/ha-syncgen/internal/config"
+	"github.com/HasithDeAlwis/ha-syncgen/internal/generator"
+	"io"
+)
+
+type (
+	// Config is a single, defaulted and validated cluster
+	Config = config.Config
+	// Document is a parsed configuration file with a single cluster or a
+	// clusters: map, with any overlays merged in
+	Document = config.Document
+	// ValidationError is one problem found in a config, with its position
+	ValidationError = config.ValidationError
+	// Severity says whether a ValidationError is an error or a warning
+	Severity = config.Severity
+
+	// File is one generated file, with a path relative to the output root
+	File = generator.File
+	// Sink receives generated files
+	Sink = generator.Sink
+	// DirSink writes files to a directory and keeps a manifest of them
+	DirSink = generator.DirSink
+	// MemorySink keeps generated files in memory
+	MemorySink = generator.MemorySink
+	// Plan lists the changes writing to a directory would make
+	Plan = generator.Plan
+	// Change is one file in a Plan
+	Change = generator.Change
+	// Action is what writing a Plan does to one file
+	Action = generator.Action
+)
+
+const (
+	SeverityError   = config.SeverityError
+	SeverityWarning = config.SeverityWarning
+)
+
+const (
+	Create    = generator.Create
+	Update    = generator.Update
+	Unchanged = generator.Unchanged
+	Remove    = generator.Remove
+)
+
+// Load reads a configuration file without decoding or validating it. Decode
+// a cluster with Document.Cluster or all clusters with Document.All.
+func Load(filename string) (*Document, error) {
+	return config.Load(filename)
+}
+
+// LoadBytes parses configuration data; filename is only used in error positions
+func LoadBytes(filename string, data []byte) (*Document, error) {
+	return config.LoadBytes(filename, data)
+}
+
+// Parse reads, defaults and validates a single-cluster configuration file
+func Parse(filename string) (*Config, error) {
+	return config.Parse(filename)
+}
+
+// Check applies defaults to cfg and returns every error and warning found
+func Check(cfg *Config) []*ValidationError {
+	return config.Check(cfg)
+}
+
+// ValidationErrors extracts the validation errors wrapped in err
+func ValidationErrors(err error) []*ValidationError {
+	return config.ValidationErrors(err)
+}
+
+// Render generates all files for cfg in memory
+func Render(cfg *Config) ([]File, error) {
+	return generator.New(cfg, nil).Render()
+}
+
+// Generate writes all files for cfg to sink and closes it
+func Generate(cfg *Config, sink Sink) error {
+	return generator.New(cfg, sink).GenerateAll()
+}
+
+// PlanDir compares the files for cfg against a directory without writing
+func PlanDir(cfg *Config, dir string) (*Plan, error) {
+	return generator.New(cfg, nil).Plan(dir)
+}
+
+// NewDirSink returns a Sink writing to dir. It refuses to overwrite files
+// edited since the last build unless Force is set.
+func NewDirSink(dir string) *DirSink {
+	return generator.NewDirSink(dir)
+}
+
+// NewMemorySink returns a Sink that keeps files in memory
+func NewMemorySink() *MemorySink {
+	return generator.NewMemorySink()
+}
+
+// NewTarSink returns a Sink writing a gzip-compressed tar stream to w
+func NewTarSink(w io.Writer) Sink {
+	return generator.NewTarSink(w)
+}
+
+// NewZipSink returns a Sink writing a zip archive to w
+func NewZipSink(w io.Writer) Sink {
+	return generator.NewZipSink(w)
+}